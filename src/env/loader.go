@@ -1,16 +1,49 @@
 package env
 
 import (
+	"fmt"
 	"log"
 	"os"
+	"path/filepath"
 
+	"github.com/alexivashchenko/go-dev-server/helpers"
 	"github.com/joho/godotenv"
 )
 
+// File returns the .env file in use, the one of the server root or else the one of the current directory
+func File() string {
+	envFile := filepath.Join(helpers.GetRootDirectory(), ".env")
+	if _, err := os.Stat(envFile); err != nil {
+		if _, err := os.Stat(".env"); err == nil {
+			return ".env"
+		}
+	}
+
+	return envFile
+}
+
+// Read returns the variables of the .env file without the ones inherited from the system, a missing file has none
+func Read() (map[string]string, error) {
+	envFile := File()
+	if _, err := os.Stat(envFile); os.IsNotExist(err) {
+		return map[string]string{}, nil
+	}
+
+	values, err := godotenv.Read(envFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", envFile, err)
+	}
+
+	return values, nil
+}
+
+// Load reads .env from the server root, falling back to the current directory
 func Load() {
 
-	if _, err := os.Stat(".env"); err == nil {
-		err := godotenv.Load()
+	envFile := File()
+
+	if _, err := os.Stat(envFile); err == nil {
+		err := godotenv.Load(envFile)
 		if err != nil {
 			log.Fatal("Error loading .env file")
 		}
@@ -0,0 +1,65 @@
+package env
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/alexivashchenko/go-dev-server/helpers"
+)
+
+// Set stores a variable in the .env file Load reads and in the environment of this process
+func Set(name, value string) error {
+	envFile := File()
+
+	lines, err := helpers.ReadLinesIntoSlice(envFile)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	quoted, err := quote(value)
+	if err != nil {
+		return err
+	}
+
+	// Replace the existing assignment in place so comments and order are kept
+	entry := name + "=" + quoted
+	replaced := false
+	for i, line := range lines {
+		key, _, ok := strings.Cut(strings.TrimSpace(line), "=")
+		if ok && strings.TrimSpace(strings.TrimPrefix(key, "export ")) == name {
+			lines[i] = entry
+			replaced = true
+		}
+	}
+	if !replaced {
+		lines = append(lines, entry)
+	}
+
+	if err := helpers.WriteFileAtomic(envFile, []byte(strings.Join(lines, "\n")+"\n")); err != nil {
+		return fmt.Errorf("failed to write %s: %w", envFile, err)
+	}
+
+	return os.Setenv(name, value)
+}
+
+// quote returns a .env value godotenv reads back unchanged, single-quoted like the rest of the file when possible
+func quote(value string) (string, error) {
+	// Single quotes keep backslashes of Windows paths but can't hold a quote
+	if !strings.ContainsAny(value, "'\r\n") && !strings.HasSuffix(value, `\`) {
+		return "'" + value + "'", nil
+	}
+
+	// A backslash before the closing quote escapes it, such values only work unquoted
+	if strings.HasSuffix(value, `\`) {
+		if strings.ContainsAny(value, " \t\r\n#$'\"") {
+			return "", fmt.Errorf("value %q can't be stored in .env", value)
+		}
+		return value, nil
+	}
+
+	// Double quotes unescape backslashes and expand $VARIABLES
+	replacer := strings.NewReplacer(`\`, `\\`, `"`, `\"`, `$`, `\$`, "\n", `\n`, "\r", `\r`)
+	return `"` + replacer.Replace(value) + `"`, nil
+}
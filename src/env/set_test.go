@@ -0,0 +1,88 @@
+package env
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/alexivashchenko/go-dev-server/helpers"
+	"github.com/joho/godotenv"
+)
+
+func TestSetKeepsValuesReadable(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		wantErr bool
+	}{
+		{name: "folder", value: "php-8.3.16-Win32-vs16-x64"},
+		{name: "windows path", value: `C:\server\apps\php`},
+		{name: "trailing backslash", value: `C:\server\`},
+		{name: "quote and trailing backslash", value: `C:\O'Brien\`, wantErr: true},
+		{name: "single quote", value: `C:\Users\O'Brien\server`},
+		{name: "double quote", value: `say "hi"`},
+		{name: "dollar", value: "it's $HOME"},
+		{name: "empty", value: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			if err := helpers.SetRootDirectory(dir); err != nil {
+				t.Fatal(err)
+			}
+			envFile := filepath.Join(dir, ".env")
+			if err := os.WriteFile(envFile, []byte("# comment\nOTHER='kept'\nTEST_VALUE='old'\n"), 0644); err != nil {
+				t.Fatal(err)
+			}
+			t.Setenv("TEST_VALUE", "")
+
+			err := Set("TEST_VALUE", tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Set() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			values, err := godotenv.Read(envFile)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if values["TEST_VALUE"] != tt.value {
+				t.Errorf("TEST_VALUE = %q, want %q", values["TEST_VALUE"], tt.value)
+			}
+			if values["OTHER"] != "kept" {
+				t.Errorf("OTHER = %q, want %q", values["OTHER"], "kept")
+			}
+		})
+	}
+}
+
+func TestSetWritesTheFileLoadReads(t *testing.T) {
+	// The root has no .env, the one of the working directory is used
+	root := t.TempDir()
+	if err := helpers.SetRootDirectory(root); err != nil {
+		t.Fatal(err)
+	}
+	t.Chdir(t.TempDir())
+	if err := os.WriteFile(".env", []byte("OTHER='kept'\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("TEST_VALUE", "")
+
+	if err := Set("TEST_VALUE", "new"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := os.Stat(filepath.Join(root, ".env")); !os.IsNotExist(err) {
+		t.Errorf("Set created %s", filepath.Join(root, ".env"))
+	}
+	values, err := godotenv.Read(".env")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if values["TEST_VALUE"] != "new" || values["OTHER"] != "kept" {
+		t.Errorf(".env = %v, want TEST_VALUE=new next to OTHER=kept", values)
+	}
+}
@@ -0,0 +1,151 @@
+package health
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/alexivashchenko/go-dev-server/helpers"
+)
+
+// Probe checks once whether a service is ready to accept requests
+type Probe func() error
+
+// attemptTimeout limits how long a single probe attempt may take
+const attemptTimeout = 2 * time.Second
+
+// pollInterval is the delay between two probe attempts
+const pollInterval = 250 * time.Millisecond
+
+// WaitFor runs a probe until it passes or the timeout expires
+func WaitFor(probe Probe, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+
+	for {
+		err := probe()
+		if err == nil {
+			return nil
+		}
+
+		if time.Now().Add(pollInterval).After(deadline) {
+			return fmt.Errorf("not ready after %s: %w", timeout, err)
+		}
+
+		time.Sleep(pollInterval)
+	}
+}
+
+// All combines probes into one that passes when every probe passes
+func All(probes ...Probe) Probe {
+	return func() error {
+		for _, probe := range probes {
+			if err := probe(); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// PIDFile returns a probe that passes while a process recorded in the PID file is alive
+func PIDFile(filename string) Probe {
+	return func() error {
+		pids, err := helpers.GetRunningPIDs(filename)
+		if err != nil {
+			return err
+		}
+		if len(pids) == 0 {
+			return fmt.Errorf("no running process recorded in %s", filename)
+		}
+		return nil
+	}
+}
+
+// TCP returns a probe that passes when a TCP connection can be opened
+func TCP(address string) Probe {
+	return func() error {
+		conn, err := net.DialTimeout("tcp", address, attemptTimeout)
+		if err != nil {
+			return fmt.Errorf("tcp %s: %w", address, err)
+		}
+		conn.Close()
+		return nil
+	}
+}
+
+// HTTP returns a probe that passes when the URL answers with a non-server-error status
+func HTTP(url string) Probe {
+	client := &http.Client{
+		Timeout: attemptTimeout,
+		// Redirects still prove the server is up
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+
+	return func() error {
+		resp, err := client.Get(url)
+		if err != nil {
+			return fmt.Errorf("http %s: %w", url, err)
+		}
+		defer resp.Body.Close()
+		io.Copy(io.Discard, resp.Body)
+
+		if resp.StatusCode >= 500 {
+			return fmt.Errorf("http %s: status %d", url, resp.StatusCode)
+		}
+		return nil
+	}
+}
+
+// FastCGI record types used by the ping
+const (
+	fcgiVersion         = 1
+	fcgiGetValues       = 9
+	fcgiGetValuesResult = 10
+)
+
+// FastCGI returns a probe that sends FCGI_GET_VALUES to a FastCGI server and waits for the result record
+func FastCGI(address string) Probe {
+	return func() error {
+		conn, err := net.DialTimeout("tcp", address, attemptTimeout)
+		if err != nil {
+			return fmt.Errorf("fastcgi %s: %w", address, err)
+		}
+		defer conn.Close()
+		conn.SetDeadline(time.Now().Add(attemptTimeout))
+
+		// Ask for FCGI_MPXS_CONNS as a name-value pair with an empty value
+		name := "FCGI_MPXS_CONNS"
+		content := append([]byte{byte(len(name)), 0}, name...)
+		if _, err := conn.Write(fcgiRecord(fcgiGetValues, content)); err != nil {
+			return fmt.Errorf("fastcgi %s: %w", address, err)
+		}
+
+		header := make([]byte, 8)
+		if _, err := io.ReadFull(conn, header); err != nil {
+			return fmt.Errorf("fastcgi %s: %w", address, err)
+		}
+		if header[0] != fcgiVersion || header[1] != fcgiGetValuesResult {
+			return errors.New("fastcgi " + address + ": unexpected response")
+		}
+
+		return nil
+	}
+}
+
+// fcgiRecord builds a management record (request id 0) with padding to 8 bytes
+func fcgiRecord(recordType byte, content []byte) []byte {
+	padding := (8 - len(content)%8) % 8
+	record := make([]byte, 8, 8+len(content)+padding)
+	record[0] = fcgiVersion
+	record[1] = recordType
+	binary.BigEndian.PutUint16(record[4:6], uint16(len(content)))
+	record[6] = byte(padding)
+	record = append(record, content...)
+	return append(record, make([]byte, padding)...)
+}
@@ -2,6 +2,7 @@ package helpers
 
 import (
 	"bufio"
+	"encoding/base64"
 	"errors"
 	"fmt"
 	"io"
@@ -11,8 +12,12 @@ import (
 	"os/exec"
 	"path/filepath"
 	"runtime"
+	"slices"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
+	"unicode/utf16"
 )
 
 // FileSystem operations
@@ -143,43 +148,35 @@ func AppendLines(filename string, lines []string) error {
 	return nil
 }
 
-// ReplaceInFileByMap replaces multiple strings in a file based on a map
-func ReplaceInFileByMap(filename string, replacements map[string]string) error {
-	// Read content
-	content, err := os.ReadFile(filename)
-	if err != nil {
-		return fmt.Errorf("failed to read file %s: %w", filename, err)
-	}
-
-	newContent := string(content)
-	for old, new := range replacements {
-		newContent = strings.ReplaceAll(newContent, old, new)
+// WriteFileAtomic writes content to a temporary file next to filename and renames it into place
+func WriteFileAtomic(filename string, content []byte) error {
+	dir := filepath.Dir(filename)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create directory %s: %w", dir, err)
 	}
 
-	// Write back
-	err = os.WriteFile(filename, []byte(newContent), 0644)
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(filename)+".*.tmp")
 	if err != nil {
-		return fmt.Errorf("failed to write file %s: %w", filename, err)
+		return fmt.Errorf("failed to create temporary file for %s: %w", filename, err)
 	}
+	tmpName := tmp.Name()
 
-	return nil
-}
-
-// ReplaceInFile replaces a string in a file
-func ReplaceInFile(filename, old, new string) error {
-	// Read content
-	content, err := os.ReadFile(filename)
-	if err != nil {
-		return fmt.Errorf("failed to read file %s: %w", filename, err)
+	if _, err := tmp.Write(content); err != nil {
+		tmp.Close()
+		os.Remove(tmpName)
+		return fmt.Errorf("failed to write temporary file for %s: %w", filename, err)
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmpName)
+		return fmt.Errorf("failed to close temporary file for %s: %w", filename, err)
+	}
+	if err := os.Chmod(tmpName, 0644); err != nil {
+		log.Printf("Warning: Failed to set permissions on %s: %v", tmpName, err)
 	}
 
-	// Replace content
-	newContent := strings.ReplaceAll(string(content), old, new)
-
-	// Write back
-	err = os.WriteFile(filename, []byte(newContent), 0644)
-	if err != nil {
-		return fmt.Errorf("failed to write file %s: %w", filename, err)
+	if err := os.Rename(tmpName, filename); err != nil {
+		os.Remove(tmpName)
+		return fmt.Errorf("failed to replace file %s: %w", filename, err)
 	}
 
 	return nil
@@ -225,7 +222,7 @@ func CopyFile(src, dst string) error {
 // CopyFileAsAdmin copies a file with administrative privileges
 func CopyFileAsAdmin(source, destination string) error {
 	if runtime.GOOS == "windows" {
-		err := RunPowerShellAsAdmin(fmt.Sprintf("Copy-Item -Path \"%s\" -Destination \"%s\" -Force", source, destination))
+		err := RunPowerShellAsAdmin(fmt.Sprintf("Copy-Item -LiteralPath %s -Destination %s -Force", QuotePowerShell(source), QuotePowerShell(destination)))
 		if err != nil {
 			return fmt.Errorf("failed to copy file as admin from %s to %s: %w", source, destination, err)
 		}
@@ -277,8 +274,9 @@ func RunPowerShellAsAdmin(command string) error {
 
 	// log.Printf("Running PowerShell command as admin: %s", command)
 
-	cmd := exec.Command("powershell", "-Command",
-		fmt.Sprintf("Start-Process powershell -Verb RunAs -Wait -ArgumentList '-NoProfile -ExecutionPolicy Bypass -Command \"%s\"'", command))
+	// The encoded command survives both PowerShell command lines whatever quotes or spaces it contains
+	cmd := exec.Command("powershell", "-NoProfile", "-Command",
+		fmt.Sprintf("Start-Process powershell -Verb RunAs -Wait -ArgumentList '-NoProfile -ExecutionPolicy Bypass -EncodedCommand %s'", encodePowerShellCommand(command)))
 
 	output, err := cmd.CombinedOutput()
 	if err != nil {
@@ -288,244 +286,416 @@ func RunPowerShellAsAdmin(command string) error {
 	return nil
 }
 
-// KillProcess terminates a process by name
-func KillProcess(process string) error {
-	log.Printf("Killing process: %s", process)
+// QuotePowerShell returns a PowerShell string literal holding value verbatim
+func QuotePowerShell(value string) string {
+	return "'" + strings.ReplaceAll(value, "'", "''") + "'"
+}
 
-	if runtime.GOOS == "windows" {
-		cmd := exec.Command("taskkill", "/F", "/IM", process)
-		output, err := cmd.CombinedOutput()
-		if err != nil {
-			// Check if the error is because the process is not running
-			if strings.Contains(string(output), "not found") {
-				log.Printf("Process %s is not running", process)
-				return nil
-			}
-			return fmt.Errorf("failed to kill process %s: %s, error: %w", process, string(output), err)
-		}
-	} else {
-		// For Unix-like systems
-		cmd := exec.Command("pkill", "-f", process)
-		output, err := cmd.CombinedOutput()
-		if err != nil {
-			// Exit code 1 means no processes matched, which is fine
-			if exitErr, ok := err.(*exec.ExitError); ok && exitErr.ExitCode() == 1 {
-				log.Printf("Process %s is not running", process)
-				return nil
-			}
-			return fmt.Errorf("failed to kill process %s: %s, error: %w", process, string(output), err)
-		}
+// encodePowerShellCommand encodes a command for -EncodedCommand, base64 of its UTF-16LE bytes
+func encodePowerShellCommand(command string) string {
+	units := utf16.Encode([]rune(command))
+	encoded := make([]byte, 0, len(units)*2)
+	for _, unit := range units {
+		encoded = append(encoded, byte(unit), byte(unit>>8))
+	}
+
+	return base64.StdEncoding.EncodeToString(encoded)
+}
+
+// PID files
+
+// GetPIDFilePath returns the path of the PID file for a service
+func GetPIDFilePath(service string) string {
+	return filepath.Join(GetRootDirectory(), "run", strings.ToLower(service)+".pid")
+}
+
+// WritePIDFile writes the given PIDs into a PID file, one per line
+func WritePIDFile(filename string, pids ...int) error {
+	dir := filepath.Dir(filename)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create directory %s: %w", dir, err)
+	}
+
+	lines := make([]string, 0, len(pids))
+	for _, pid := range pids {
+		lines = append(lines, strconv.Itoa(pid))
+	}
+
+	if err := os.WriteFile(filename, []byte(strings.Join(lines, "\n")+"\n"), 0644); err != nil {
+		return fmt.Errorf("failed to write PID file %s: %w", filename, err)
 	}
 
 	return nil
 }
 
-// RunCommand executes a command
-func RunCommand(command string, inBackground bool) error {
-	// log.Printf("Running command: %s", command)
+// ReadPIDFile reads the PIDs stored in a PID file, a missing file means no PIDs
+func ReadPIDFile(filename string) ([]int, error) {
+	content, err := os.ReadFile(filename)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to read PID file %s: %w", filename, err)
+	}
 
-	var err error
-	if inBackground {
-		err = runCommandInBackground(command)
-	} else {
-		_, err = RunCommandWithOutput(command)
+	var pids []int
+	for _, line := range strings.Fields(string(content)) {
+		pid, err := strconv.Atoi(line)
+		if err != nil {
+			return nil, fmt.Errorf("invalid PID %q in %s", line, filename)
+		}
+		pids = append(pids, pid)
 	}
 
-	return err
+	return pids, nil
 }
 
-// RunCommandWithOutput executes a command and returns its output
-func RunCommandWithOutput(command string) (string, error) {
-	var cmd *exec.Cmd
+// pidStartTolerance covers the coarse start times some systems report, e.g. /proc counts from the boot second
+const pidStartTolerance = 2 * time.Second
 
-	if runtime.GOOS == "windows" {
-		cmd = exec.Command("cmd", "/C", command)
-	} else {
-		cmd = exec.Command("sh", "-c", command)
+// isRecordedProcess reports whether a PID still belongs to the process recorded in a PID file written at the given time,
+// a process started after the file was written reuses the PID of a process that died, e.g. before a reboot
+func isRecordedProcess(pid int, written time.Time) bool {
+	if !IsPIDRunning(pid) {
+		return false
 	}
 
-	output, err := cmd.CombinedOutput()
+	startedAt, err := ProcessStartTime(pid)
 	if err != nil {
-		return string(output), fmt.Errorf("command failed: %s, error: %w", string(output), err)
+		// Without a start time the PID is trusted like before
+		return true
 	}
 
-	return string(output), nil
+	return !startedAt.After(written.Add(pidStartTolerance))
 }
 
-// RunCommandInDirectory executes a command in a specific directory
-func RunCommandInDirectory(command string, directory string, inBackground bool) error {
-	log.Printf("Running command in directory %s: %s", directory, command)
+// GetRunningPIDs returns the PIDs of a PID file that are still alive and drops the stale ones,
+// including PIDs reused by processes started after the file was written
+func GetRunningPIDs(filename string) ([]int, error) {
+	pids, err := ReadPIDFile(filename)
+	if err != nil || len(pids) == 0 {
+		return nil, err
+	}
 
-	var cmd *exec.Cmd
+	info, err := os.Stat(filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read PID file %s: %w", filename, err)
+	}
 
-	if runtime.GOOS == "windows" {
-		cmd = exec.Command("cmd", "/C", command)
-	} else {
-		cmd = exec.Command("sh", "-c", command)
+	var running []int
+	for _, pid := range pids {
+		if isRecordedProcess(pid, info.ModTime()) {
+			running = append(running, pid)
+		}
+	}
+
+	if len(running) == len(pids) {
+		return running, nil
+	}
+
+	// Some processes died without cleaning up
+	log.Printf("Removing stale PIDs from %s", filename)
+	if len(running) == 0 {
+		return nil, RemoveFile(filename)
 	}
 
-	cmd.Dir = directory
+	return running, WritePIDFile(filename, running...)
+}
+
+// StopPIDFile terminates the processes of a PID file, kills them after the timeout and removes the file
+func StopPIDFile(filename string, timeout time.Duration) error {
+	pids, err := GetRunningPIDs(filename)
+	if err != nil {
+		return err
+	}
+
+	if len(pids) == 0 {
+		log.Printf("No running processes found in %s", filename)
+		return RemoveFile(filename)
+	}
 
-	if inBackground {
-		if err := cmd.Start(); err != nil {
-			return fmt.Errorf("failed to start command: %w", err)
+	for _, pid := range pids {
+		log.Printf("Stopping process %d", pid)
+		process, err := os.FindProcess(pid)
+		if err != nil {
+			continue
+		}
+		if err := TerminateProcess(process); err != nil {
+			log.Printf("Warning: Failed to terminate process %d: %v", pid, err)
 		}
+	}
 
-		// Detach the process
-		if runtime.GOOS != "windows" {
-			setProcessGroupID(cmd)
+	// Wait for graceful shutdown, then kill whatever is left
+	deadline := time.Now().Add(timeout)
+	for _, pid := range pids {
+		for IsPIDRunning(pid) && time.Now().Before(deadline) {
+			time.Sleep(200 * time.Millisecond)
 		}
 
-		return nil
+		if IsPIDRunning(pid) {
+			log.Printf("Process %d did not stop within %s, killing it", pid, timeout)
+			process, err := os.FindProcess(pid)
+			if err == nil {
+				if err := process.Kill(); err != nil {
+					return fmt.Errorf("failed to kill process %d: %w", pid, err)
+				}
+			}
+		}
 	}
 
-	output, err := cmd.CombinedOutput()
+	return RemoveFile(filename)
+}
+
+// Application helpers
+
+// GetCommand returns the command and its arguments from command line arguments,
+// flags may come before the command
+func GetCommand() (string, []string, error) {
+	command := ""
+	var args []string
+	rest := os.Args[1:]
+	for i := 0; i < len(rest); i++ {
+		arg := rest[i]
+		if command == "" && !strings.HasPrefix(arg, "-") {
+			command = arg
+			continue
+		}
+		args = append(args, arg)
+
+		// The value of --root PATH is not the command
+		if takesValue(arg) && hasValue(rest, i) {
+			i++
+			args = append(args, rest[i])
+		}
+	}
+
+	if command == "" {
+		command = "start"
+	}
+
+	allowedCommands := []string{"start", "stop", "restart", "reload", "watch", "status", "logs", "link", "unlink", "links", "php", "xdebug", "run", "help", "php-workers"}
+
+	for _, allowedCommand := range allowedCommands {
+		if allowedCommand == command {
+			return command, args, nil
+		}
+	}
+
+	return "", args, fmt.Errorf("unknown command: %s", command)
+}
+
+// GetEnvDuration reads a duration such as "30s" or a plain number of seconds from the environment
+func GetEnvDuration(name string, fallback time.Duration) time.Duration {
+	value := strings.TrimSpace(os.Getenv(name))
+	if value == "" {
+		return fallback
+	}
+
+	if seconds, err := strconv.Atoi(value); err == nil {
+		return time.Duration(seconds) * time.Second
+	}
+
+	duration, err := time.ParseDuration(value)
 	if err != nil {
-		return fmt.Errorf("command failed: %s, error: %w", string(output), err)
+		log.Printf("Warning: Invalid duration %q in %s, using default: %s", value, name, fallback)
+		return fallback
 	}
 
-	return nil
+	return duration
 }
 
-// runCommandAndWait executes a command and waits for it to complete
-func runCommandAndWait(command string) (string, error) {
-	return RunCommandWithOutput(command)
+// GetEnvInt reads a non-negative integer from the environment
+func GetEnvInt(name string, fallback int) int {
+	value := strings.TrimSpace(os.Getenv(name))
+	if value == "" {
+		return fallback
+	}
+
+	number, err := strconv.Atoi(value)
+	if err != nil || number < 0 {
+		log.Printf("Warning: Invalid number %q in %s, using default: %d", value, name, fallback)
+		return fallback
+	}
+
+	return number
 }
 
-// runCommandInBackground executes a command in the background
-func runCommandInBackground(command string) error {
-	var cmd *exec.Cmd
+// ParseBool reads a flag such as "true", "1", "yes" or "on", the values accepted in .env and .devserver files
+func ParseBool(value string) (bool, error) {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "true", "1", "yes", "on":
+		return true, nil
+	case "false", "0", "no", "off":
+		return false, nil
+	default:
+		return false, fmt.Errorf("invalid flag %q", value)
+	}
+}
 
-	if runtime.GOOS == "windows" {
-		cmd = exec.Command("cmd", "/C", command)
-	} else {
-		cmd = exec.Command("sh", "-c", command)
+// GetEnvBool reads a flag such as "true", "1", "yes" or "on" from the environment
+func GetEnvBool(name string, fallback bool) bool {
+	value := os.Getenv(name)
+	if strings.TrimSpace(value) == "" {
+		return fallback
 	}
 
-	// Detach the process on Unix systems
-	if runtime.GOOS != "windows" {
-		setProcessGroupID(cmd)
+	enabled, err := ParseBool(value)
+	if err != nil {
+		log.Printf("Warning: Invalid flag %q in %s, using default: %t", value, name, fallback)
+		return fallback
 	}
 
-	if err := cmd.Start(); err != nil {
-		return fmt.Errorf("failed to start command: %w", err)
+	return enabled
+}
+
+// valueFlags always take a value, which may also follow as the next argument: --root PATH
+var valueFlags = []string{"root", "lines", "since", "grep", "mode"}
+
+// takesValue reports whether an argument is a value flag without =value
+func takesValue(arg string) bool {
+	if !strings.HasPrefix(arg, "-") || strings.Contains(arg, "=") {
+		return false
 	}
 
-	return nil
+	return slices.Contains(valueFlags, strings.TrimLeft(arg, "-"))
 }
 
-// IsProcessRunning checks if a process is running and returns its PID
-func IsProcessRunning(processName string) (bool, int) {
-	if runtime.GOOS == "windows" {
-		// For Windows, use PowerShell to check if process is running
-		command := fmt.Sprintf("powershell -Command \"Get-Process -Name '%s' -ErrorAction SilentlyContinue | Select-Object -ExpandProperty Id\"",
-			strings.TrimSuffix(processName, ".exe"))
-		output, err := RunCommandWithOutput(command)
-		if err != nil || output == "" {
-			return false, 0
+// hasValue reports whether the argument after index i can be the value of a flag
+func hasValue(args []string, i int) bool {
+	return i+1 < len(args) && !strings.HasPrefix(args[i+1], "-")
+}
+
+// ParseArgs splits command arguments into positional values and --flag[=value] options,
+// a value flag without a value is set to an empty string
+func ParseArgs(args []string) ([]string, map[string]string) {
+	var values []string
+	flags := make(map[string]string)
+
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if !strings.HasPrefix(arg, "-") || arg == "-" {
+			values = append(values, arg)
+			continue
 		}
 
-		// Parse the PID from PowerShell output
-		output = strings.TrimSpace(output)
-		if output != "" {
-			var pid int
-			// PowerShell may return multiple PIDs, take the first one
-			firstLine := strings.Split(output, "\n")[0]
-			fmt.Sscanf(firstLine, "%d", &pid)
-			return true, pid
+		name := strings.TrimLeft(arg, "-")
+		if takesValue(arg) {
+			flags[name] = ""
+			if hasValue(args, i) {
+				i++
+				flags[name] = args[i]
+			}
+			continue
 		}
-	} else {
-		// For Unix-like systems
-		output, err := RunCommandWithOutput(fmt.Sprintf("pgrep -f %s", processName))
-		if err == nil && output != "" {
-			var pid int
-			fmt.Sscanf(output, "%d", &pid)
-			return true, pid
+
+		value := "true"
+		if index := strings.Index(name, "="); index >= 0 {
+			name, value = name[:index], name[index+1:]
 		}
+		flags[name] = value
 	}
 
-	return false, 0
+	return values, flags
 }
 
-// Application helpers
+// RootMarkerFile marks the server root directory
+const RootMarkerFile = ".devserver-root"
 
-// GetCommand returns the command from command line arguments
-func GetCommand() (string, error) {
-	command := "start"
-	if len(os.Args) > 1 {
-		command = os.Args[1]
-	}
+var (
+	rootDirMu     sync.Mutex
+	rootDir       string
+	rootDirSource string
+)
 
-	allowedCommands := []string{"start", "stop", "restart", "status", "help"}
+// SetRootDirectory overrides root directory detection, used by the --root flag
+func SetRootDirectory(dir string) error {
+	absDir, err := filepath.Abs(dir)
+	if err != nil {
+		return fmt.Errorf("failed to resolve root directory %s: %w", dir, err)
+	}
 
-	for _, allowedCommand := range allowedCommands {
-		if allowedCommand == command {
-			return command, nil
-		}
+	info, err := os.Stat(absDir)
+	if err != nil {
+		return fmt.Errorf("root directory %s: %w", absDir, err)
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("root directory %s is not a directory", absDir)
 	}
 
-	return "", fmt.Errorf("unknown command: %s", command)
+	rootDirMu.Lock()
+	defer rootDirMu.Unlock()
+	rootDir, rootDirSource = absDir, "--root"
+
+	return nil
 }
 
+// GetRootDirectory returns the server root, resolved once from
+// DEVSERVER_ROOT, a marker file above the executable or the working directory
 func GetRootDirectory() string {
+	rootDirMu.Lock()
+	defer rootDirMu.Unlock()
 
-	currentDir, _ := os.Getwd()
-
-	parentDir := filepath.Dir(currentDir)
-
-	if checkBuild() {
-		return parentDir
-
+	if rootDir == "" {
+		rootDir, rootDirSource = resolveRootDirectory()
 	}
 
-	return currentDir
+	return rootDir
 }
 
-func checkBuild() bool {
-	cmd := exec.Command("go", "build", "-v")
-	err := cmd.Run()
+// GetRootDirectorySource describes how the root directory was found
+func GetRootDirectorySource() string {
+	GetRootDirectory()
 
-	return err == nil
+	rootDirMu.Lock()
+	defer rootDirMu.Unlock()
+
+	return rootDirSource
 }
 
-// // GetRootDirectory returns the root directory of the application
-// func GetRootDirectory() string {
-// 	execPath, err := os.Executable()
-// 	if err == nil {
-// 		// Use the directory of the executable
-// 		return filepath.Dir(execPath)
-// 	}
+// resolveRootDirectory finds the root directory and returns it with the source it came from
+func resolveRootDirectory() (string, string) {
+	if dir := os.Getenv("DEVSERVER_ROOT"); dir != "" {
+		if absDir, err := filepath.Abs(dir); err == nil {
+			return absDir, "DEVSERVER_ROOT"
+		}
+		log.Printf("Warning: Invalid DEVSERVER_ROOT %s, ignoring it", dir)
+	}
+
+	if execPath, err := os.Executable(); err == nil {
+		if execPath, err := filepath.EvalSymlinks(execPath); err == nil {
+			if dir, ok := findMarkerUpwards(filepath.Dir(execPath)); ok {
+				return dir, "marker file above executable"
+			}
+		}
+	}
 
-// 	// Fallback to current directory
-// 	currentDir, err := os.Getwd()
-// 	if err != nil {
-// 		log.Printf("Warning: Failed to get current directory: %v", err)
-// 		return "."
-// 	}
+	currentDir, err := os.Getwd()
+	if err != nil {
+		log.Printf("Warning: Failed to get current directory: %v", err)
+		return ".", "current directory"
+	}
 
-// 	// Check if we're in development mode
-// 	if isDevMode() {
-// 		// In development mode, use the parent directory if we're in the src directory
-// 		if filepath.Base(currentDir) == "src" {
-// 			return filepath.Dir(currentDir)
-// 		}
-// 	}
+	// Covers `go run .` from src, where the executable lives in a temporary directory
+	if dir, ok := findMarkerUpwards(currentDir); ok {
+		return dir, "marker file above current directory"
+	}
 
-// 	return currentDir
-// }
+	return currentDir, "current directory"
+}
 
-// // isDevMode checks if the application is running in development mode
-// func isDevMode() bool {
-// 	// Check if go.mod exists in the current directory
-// 	_, err := os.Stat("go.mod")
-// 	return err == nil
-// }
+// findMarkerUpwards looks for RootMarkerFile in dir and its parents
+func findMarkerUpwards(dir string) (string, bool) {
+	for {
+		if _, err := os.Stat(filepath.Join(dir, RootMarkerFile)); err == nil {
+			return dir, true
+		}
 
-// // checkBuild checks if the go build command is available
-// func checkBuild() bool {
-// 	cmd := exec.Command("go", "version")
-// 	return cmd.Run() == nil
-// }
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", false
+		}
+		dir = parent
+	}
+}
 
 // Network helpers
 
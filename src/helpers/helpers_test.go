@@ -0,0 +1,202 @@
+package helpers
+
+import (
+	"maps"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestGetRunningPIDsDropsReusedPIDs(t *testing.T) {
+	if runtime.GOOS != "windows" && runtime.GOOS != "linux" {
+		t.Skip("process start times are only read on Windows and Linux")
+	}
+
+	tests := []struct {
+		name    string
+		written time.Time
+		want    int
+	}{
+		{name: "written after the process started", written: time.Now(), want: 1},
+		{name: "written before the process started", written: time.Now().Add(-24 * time.Hour), want: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filename := filepath.Join(t.TempDir(), "test.pid")
+			if err := WritePIDFile(filename, os.Getpid()); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.Chtimes(filename, tt.written, tt.written); err != nil {
+				t.Fatal(err)
+			}
+
+			pids, err := GetRunningPIDs(filename)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(pids) != tt.want {
+				t.Errorf("GetRunningPIDs() = %v, want %d PIDs", pids, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadPIDFile(t *testing.T) {
+	dir := t.TempDir()
+
+	tests := []struct {
+		name    string
+		content string
+		want    []int
+		wantErr bool
+	}{
+		{name: "one PID per line", content: "12\n34\n", want: []int{12, 34}},
+		{name: "empty file", content: "", want: nil},
+		{name: "invalid PID", content: "12\nabc\n", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filename := filepath.Join(dir, tt.name+".pid")
+			if err := os.WriteFile(filename, []byte(tt.content), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := ReadPIDFile(filename)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ReadPIDFile() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("ReadPIDFile() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestQuotePowerShell(t *testing.T) {
+	tests := []struct {
+		value string
+		want  string
+	}{
+		{value: `C:\server\etc\hosts`, want: `'C:\server\etc\hosts'`},
+		{value: `C:\Users\John Doe\server`, want: `'C:\Users\John Doe\server'`},
+		{value: `C:\Users\O'Brien\server`, want: `'C:\Users\O''Brien\server'`},
+		{value: `C:\"quoted"\server`, want: `'C:\"quoted"\server'`},
+	}
+
+	for _, tt := range tests {
+		if got := QuotePowerShell(tt.value); got != tt.want {
+			t.Errorf("QuotePowerShell(%q) = %q, want %q", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestEncodePowerShellCommand(t *testing.T) {
+	// powershell -EncodedCommand expects base64 of UTF-16LE
+	tests := []struct {
+		command string
+		want    string
+	}{
+		{command: "dir", want: "ZABpAHIA"},
+		{command: "'é'", want: "JwDpACcA"},
+		{command: "", want: ""},
+	}
+
+	for _, tt := range tests {
+		if got := encodePowerShellCommand(tt.command); got != tt.want {
+			t.Errorf("encodePowerShellCommand(%q) = %q, want %q", tt.command, got, tt.want)
+		}
+	}
+}
+
+func TestParseBool(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    bool
+		wantErr bool
+	}{
+		{value: "true", want: true},
+		{value: " Yes ", want: true},
+		{value: "ON", want: true},
+		{value: "1", want: true},
+		{value: "false", want: false},
+		{value: "no", want: false},
+		{value: "Off", want: false},
+		{value: "0", want: false},
+		{value: "", wantErr: true},
+		{value: "maybe", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, err := ParseBool(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseBool(%q) error = %v, wantErr %v", tt.value, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ParseBool(%q) = %t, want %t", tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetCommand(t *testing.T) {
+	tests := []struct {
+		name     string
+		args     []string
+		want     string
+		wantArgs []string
+	}{
+		{name: "default command", args: nil, want: "start"},
+		{name: "flag before the command", args: []string{"--root=/srv/dev", "stop", "nginx"}, want: "stop", wantArgs: []string{"--root=/srv/dev", "nginx"}},
+		{name: "root value before the command", args: []string{"--root", "/srv/dev", "stop"}, want: "stop", wantArgs: []string{"--root", "/srv/dev"}},
+		{name: "root value after the command", args: []string{"status", "--root", "/srv/dev", "php"}, want: "status", wantArgs: []string{"--root", "/srv/dev", "php"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			args := os.Args
+			defer func() { os.Args = args }()
+			os.Args = append([]string{"server"}, tt.args...)
+
+			got, gotArgs, err := GetCommand()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want || !slices.Equal(gotArgs, tt.wantArgs) {
+				t.Errorf("GetCommand() = %q %v, want %q %v", got, gotArgs, tt.want, tt.wantArgs)
+			}
+		})
+	}
+}
+
+func TestParseArgs(t *testing.T) {
+	tests := []struct {
+		name       string
+		args       []string
+		wantValues []string
+		wantFlags  map[string]string
+	}{
+		{name: "values", args: []string{"nginx", "php"}, wantValues: []string{"nginx", "php"}, wantFlags: map[string]string{}},
+		{name: "switches", args: []string{"-f", "--json"}, wantFlags: map[string]string{"f": "true", "json": "true"}},
+		{name: "flag with value", args: []string{"--root=D:\\dev", "--watch=5"}, wantFlags: map[string]string{"root": "D:\\dev", "watch": "5"}},
+		{name: "value as next argument", args: []string{"--root", "D:\\dev", "nginx"}, wantValues: []string{"nginx"}, wantFlags: map[string]string{"root": "D:\\dev"}},
+		{name: "switch keeps the next argument", args: []string{"--json", "nginx"}, wantValues: []string{"nginx"}, wantFlags: map[string]string{"json": "true"}},
+		{name: "value flag without value", args: []string{"--root"}, wantFlags: map[string]string{"root": ""}},
+		{name: "value flag followed by a flag", args: []string{"--lines", "-f"}, wantFlags: map[string]string{"lines": "", "f": "true"}},
+		{name: "dash is a value", args: []string{"-"}, wantValues: []string{"-"}, wantFlags: map[string]string{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			values, flags := ParseArgs(tt.args)
+			if !slices.Equal(values, tt.wantValues) || !maps.Equal(flags, tt.wantFlags) {
+				t.Errorf("ParseArgs(%q) = %v %v, want %v %v", tt.args, values, flags, tt.wantValues, tt.wantFlags)
+			}
+		})
+	}
+}
@@ -4,13 +4,83 @@
 package helpers
 
 import (
-	"os/exec"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
 	"syscall"
+	"time"
 )
 
-// setProcessGroupID configures a command to run in its own process group on Unix systems
-func setProcessGroupID(cmd *exec.Cmd) {
-	cmd.SysProcAttr = &syscall.SysProcAttr{
-		Setpgid: true,
+// clockTicks is the USER_HZ unit of /proc start times, 100 on every common Linux platform
+const clockTicks = 100
+
+// IsPIDRunning checks if a process with the given PID exists
+func IsPIDRunning(pid int) bool {
+	if pid <= 0 {
+		return false
+	}
+
+	process, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+
+	// Signal 0 performs error checking only
+	err = process.Signal(syscall.Signal(0))
+	return err == nil || err == syscall.EPERM
+}
+
+// ProcessStartTime returns when a process was started, it relies on /proc and fails on systems without it
+func ProcessStartTime(pid int) (time.Time, error) {
+	stat, err := os.ReadFile(fmt.Sprintf("/proc/%d/stat", pid))
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	// The command name may contain spaces, the fields after it are numbered from the state
+	end := strings.LastIndexByte(string(stat), ')')
+	if end < 0 {
+		return time.Time{}, fmt.Errorf("unexpected format of /proc/%d/stat", pid)
+	}
+	fields := strings.Fields(string(stat[end+1:]))
+	if len(fields) < 20 {
+		return time.Time{}, fmt.Errorf("unexpected format of /proc/%d/stat", pid)
+	}
+	ticks, err := strconv.ParseInt(fields[19], 10, 64)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid start time in /proc/%d/stat: %w", pid, err)
 	}
+
+	bootTime, err := systemBootTime()
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	return bootTime.Add(time.Duration(ticks) * time.Second / clockTicks), nil
+}
+
+// systemBootTime reads the boot time from /proc/stat
+func systemBootTime() (time.Time, error) {
+	content, err := os.ReadFile("/proc/stat")
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	for _, line := range strings.Split(string(content), "\n") {
+		if value, ok := strings.CutPrefix(line, "btime "); ok {
+			seconds, err := strconv.ParseInt(strings.TrimSpace(value), 10, 64)
+			if err != nil {
+				return time.Time{}, fmt.Errorf("invalid btime in /proc/stat: %w", err)
+			}
+			return time.Unix(seconds, 0), nil
+		}
+	}
+
+	return time.Time{}, fmt.Errorf("btime not found in /proc/stat")
+}
+
+// TerminateProcess asks a process to shut down gracefully
+func TerminateProcess(process *os.Process) error {
+	return process.Signal(syscall.SIGTERM)
 }
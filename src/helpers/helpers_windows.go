@@ -4,11 +4,55 @@
 package helpers
 
 import (
+	"fmt"
+	"log"
+	"os"
 	"os/exec"
+	"strings"
+	"syscall"
+	"time"
 )
 
-// setProcessGroupID is a no-op on Windows
-func setProcessGroupID(cmd *exec.Cmd) {
-	// Windows doesn't support process groups in the same way
-	// No action needed
+// processQueryLimitedInformation is enough to read the times of processes of other users
+const processQueryLimitedInformation = 0x1000
+
+// IsPIDRunning checks if a process with the given PID exists
+func IsPIDRunning(pid int) bool {
+	if pid <= 0 {
+		return false
+	}
+
+	output, err := exec.Command("tasklist", "/FI", fmt.Sprintf("PID eq %d", pid), "/NH").Output()
+	if err != nil {
+		return false
+	}
+
+	return strings.Contains(string(output), fmt.Sprintf(" %d ", pid))
+}
+
+// ProcessStartTime returns when a process was created
+func ProcessStartTime(pid int) (time.Time, error) {
+	handle, err := syscall.OpenProcess(processQueryLimitedInformation, false, uint32(pid))
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to open process %d: %w", pid, err)
+	}
+	defer syscall.CloseHandle(handle)
+
+	var creation, exit, kernel, user syscall.Filetime
+	if err := syscall.GetProcessTimes(handle, &creation, &exit, &kernel, &user); err != nil {
+		return time.Time{}, fmt.Errorf("failed to read times of process %d: %w", pid, err)
+	}
+
+	return time.Unix(0, creation.Nanoseconds()), nil
+}
+
+// TerminateProcess stops a process and its children, Windows has no SIGTERM so the tree is killed
+func TerminateProcess(process *os.Process) error {
+	cmd := exec.Command("taskkill", "/F", "/T", "/PID", fmt.Sprint(process.Pid))
+	if output, err := cmd.CombinedOutput(); err != nil {
+		log.Printf("Warning: taskkill failed for PID %d: %s", process.Pid, strings.TrimSpace(string(output)))
+		return process.Kill()
+	}
+
+	return nil
 }
@@ -0,0 +1,205 @@
+package launcher
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// Spec describes a process to launch, arguments are passed as-is without going through a shell
+type Spec struct {
+	// Executable is the path of the program to run
+	Executable string
+	Args       []string
+	// Dir is the working directory, the current directory when empty
+	Dir string
+	// Env holds KEY=VALUE pairs added to the environment of the current process
+	Env []string
+	// Stdout and Stderr are files the output is appended to, both can point to the same file
+	Stdout string
+	Stderr string
+}
+
+// Name returns the executable name used in messages
+func (s Spec) Name() string {
+	return filepath.Base(s.Executable)
+}
+
+// String returns the command line with arguments containing spaces quoted, for logging only
+func (s Spec) String() string {
+	parts := make([]string, 0, len(s.Args)+1)
+	for _, part := range append([]string{s.Executable}, s.Args...) {
+		if part == "" || strings.ContainsAny(part, " \t\"") {
+			part = fmt.Sprintf("%q", part)
+		}
+		parts = append(parts, part)
+	}
+
+	return strings.Join(parts, " ")
+}
+
+// Command returns the command for the spec with its log files opened, use Start to launch it
+func (s Spec) Command() (*exec.Cmd, error) {
+	if s.Executable == "" {
+		return nil, errors.New("no executable given")
+	}
+
+	cmd := exec.Command(s.Executable, s.Args...)
+	cmd.Dir = s.Dir
+	if len(s.Env) > 0 {
+		cmd.Env = append(os.Environ(), s.Env...)
+	}
+
+	if s.Stdout != "" {
+		file, err := openLogFile(s.Stdout)
+		if err != nil {
+			return nil, err
+		}
+		cmd.Stdout = file
+	}
+
+	if s.Stderr != "" {
+		if s.Stderr == s.Stdout {
+			cmd.Stderr = cmd.Stdout
+		} else {
+			file, err := openLogFile(s.Stderr)
+			if err != nil {
+				closeLogFiles(cmd)
+				return nil, err
+			}
+			cmd.Stderr = file
+		}
+	}
+
+	return cmd, nil
+}
+
+// openLogFile opens a log file for appending, creating its directory
+func openLogFile(filename string) (*os.File, error) {
+	if err := os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create log directory for %s: %w", filename, err)
+	}
+
+	file, err := os.OpenFile(filename, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open log file %s: %w", filename, err)
+	}
+
+	return file, nil
+}
+
+// closeLogFiles closes the log files opened by Command, the started process keeps its own handles
+func closeLogFiles(cmd *exec.Cmd) {
+	for _, writer := range []any{cmd.Stdout, cmd.Stderr} {
+		file, ok := writer.(*os.File)
+		if !ok || file == os.Stdout || file == os.Stderr {
+			continue
+		}
+		file.Close()
+	}
+}
+
+// Start launches a command built by Command, the caller must Wait for it
+func Start(cmd *exec.Cmd) error {
+	defer closeLogFiles(cmd)
+
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("failed to start %s: %w", filepath.Base(cmd.Path), err)
+	}
+
+	return nil
+}
+
+// Run runs a process to completion, output not sent to a log file is included in the error
+func Run(spec Spec) error {
+	cmd, err := spec.Command()
+	if err != nil {
+		return err
+	}
+
+	var output bytes.Buffer
+	if cmd.Stdout == nil {
+		cmd.Stdout = &output
+	}
+	if cmd.Stderr == nil {
+		cmd.Stderr = &output
+	}
+
+	if err := Start(cmd); err != nil {
+		return err
+	}
+
+	if err := cmd.Wait(); err != nil {
+		if message := strings.TrimSpace(output.String()); message != "" {
+			return fmt.Errorf("%s failed: %w: %s", spec.Name(), err, message)
+		}
+		return fmt.Errorf("%s failed: %w", spec.Name(), err)
+	}
+
+	return nil
+}
+
+// Output runs a process to completion and returns its combined output, log files are ignored
+func Output(spec Spec) (string, error) {
+	spec.Stdout, spec.Stderr = "", ""
+
+	cmd, err := spec.Command()
+	if err != nil {
+		return "", err
+	}
+
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return string(output), fmt.Errorf("%s failed: %w", spec.Name(), err)
+	}
+
+	return string(output), nil
+}
+
+// StartDetached launches a process in its own process group so it outlives the current process
+func StartDetached(spec Spec) (*exec.Cmd, error) {
+	cmd, err := spec.Command()
+	if err != nil {
+		return nil, err
+	}
+
+	if runtime.GOOS != "windows" {
+		setProcessGroupID(cmd)
+	}
+
+	if err := Start(cmd); err != nil {
+		return nil, err
+	}
+
+	return cmd, nil
+}
+
+// StartBackground launches a process detached and fails if it exits within the settle time
+func StartBackground(spec Spec, settle time.Duration) (*exec.Cmd, error) {
+	cmd, err := StartDetached(spec)
+	if err != nil {
+		return nil, err
+	}
+
+	// Reap the process so an early exit is noticed instead of leaving a zombie
+	exited := make(chan error, 1)
+	go func() {
+		exited <- cmd.Wait()
+	}()
+
+	select {
+	case err := <-exited:
+		if err == nil {
+			err = errors.New("process exited")
+		}
+		return nil, fmt.Errorf("%s exited right after start: %w", spec.Name(), err)
+	case <-time.After(settle):
+		return cmd, nil
+	}
+}
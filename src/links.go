@@ -0,0 +1,79 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"text/tabwriter"
+
+	"github.com/alexivashchenko/go-dev-server/sites"
+)
+
+// linkSite registers a folder outside www/ as a site
+func linkSite(args []string) {
+	if len(args) == 0 || len(args) > 2 {
+		fmt.Println("Usage: server link <path> [name]")
+		os.Exit(1)
+	}
+
+	name := ""
+	if len(args) == 2 {
+		name = args[1]
+	}
+
+	site, err := sites.Link(args[0], name)
+	if err != nil {
+		fmt.Printf("Error: %s\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("Linked %s to %s\n", site.Domain, site.Dir)
+	fmt.Println("Reload the sites to serve it: server reload")
+}
+
+// unlinkSite removes a linked site
+func unlinkSite(args []string) {
+	if len(args) != 1 {
+		fmt.Println("Usage: server unlink <name>")
+		os.Exit(1)
+	}
+
+	if err := sites.Unlink(args[0]); err != nil {
+		fmt.Printf("Error: %s\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("Unlinked %s\n", args[0])
+	fmt.Println("Reload the sites to stop serving it: server reload")
+}
+
+// showLinks lists the linked sites
+func showLinks() {
+	config, err := sites.NewConfiguration()
+	if err != nil {
+		fmt.Printf("Error: %s\n", err)
+		os.Exit(1)
+	}
+
+	if len(config.Links) == 0 {
+		fmt.Println("No linked sites, add one with: server link <path> [name]")
+		return
+	}
+
+	writer := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(writer, "NAME\tDOMAIN\tPATH")
+	names := make([]string, 0, len(config.Links))
+	for name := range config.Links {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		dir := config.Links[name]
+		if _, err := os.Stat(dir); err != nil {
+			dir += " (missing)"
+		}
+		fmt.Fprintf(writer, "%s\t%s\t%s\n", name, name+"."+config.DomainTail, dir)
+	}
+	writer.Flush()
+}
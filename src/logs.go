@@ -0,0 +1,110 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+
+	"github.com/alexivashchenko/go-dev-server/logs"
+	"github.com/alexivashchenko/go-dev-server/service"
+)
+
+// defaultLogLines is the number of lines shown per log file
+const defaultLogLines = 20
+
+// showLogs prints the logs of services interleaved by time, following them with -f
+func showLogs(services []service.Service, flags map[string]string) {
+	options := logs.Options{
+		Lines:  defaultLogLines,
+		Follow: flags["f"] != "" || flags["follow"] != "",
+		Color:  useColor(),
+	}
+
+	if value := flags["lines"]; value != "" {
+		lines, err := strconv.Atoi(value)
+		if err != nil || lines < 0 {
+			fmt.Printf("Error: invalid --lines value %q\n", value)
+			os.Exit(1)
+		}
+		options.Lines = lines
+	}
+
+	if value := flags["since"]; value != "" {
+		since, err := logs.ParseSince(value)
+		if err != nil {
+			fmt.Printf("Error: %s\n", err)
+			os.Exit(1)
+		}
+		options.Since = since
+	}
+
+	if value := flags["grep"]; value != "" {
+		pattern, err := regexp.Compile(value)
+		if err != nil {
+			fmt.Printf("Error: invalid --grep pattern: %s\n", err)
+			os.Exit(1)
+		}
+		options.Grep = pattern
+	}
+
+	sources := logSources(services)
+	if len(sources) == 0 {
+		fmt.Println("No log files found")
+		return
+	}
+
+	if err := logs.Show(sources, options); err != nil {
+		fmt.Printf("Error: %s\n", err)
+		os.Exit(1)
+	}
+}
+
+// clearLogs empties the logs of services and removes their rotated files and archives
+func clearLogs(services []service.Service) {
+	for _, source := range logSources(services) {
+		if err := logs.Clear(source.File); err != nil {
+			fmt.Printf("Warning: %v\n", err)
+			continue
+		}
+		fmt.Printf("Cleared %s\n", source.File)
+	}
+}
+
+// logSources returns the captured output and the own log files of services
+func logSources(services []service.Service) []logs.Source {
+	var sources []logs.Source
+	seen := make(map[string]bool)
+
+	for _, s := range services {
+		files := []string{logs.OutputFile(s.Name())}
+		if logged, ok := s.(service.Logged); ok {
+			files = append(files, logged.LogFiles()...)
+		}
+
+		for _, file := range files {
+			if seen[file] {
+				continue
+			}
+			seen[file] = true
+
+			// Files that don't exist yet are only picked up by a later run
+			if _, err := os.Stat(file); err != nil {
+				continue
+			}
+			sources = append(sources, logs.Source{Service: s.Name(), File: file})
+		}
+	}
+
+	return sources
+}
+
+// useColor reports whether output goes to a terminal and NO_COLOR is not set
+func useColor() bool {
+	if os.Getenv("NO_COLOR") != "" {
+		return false
+	}
+
+	info, err := os.Stdout.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
@@ -0,0 +1,190 @@
+package logs
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// archiveTimeLayout is the timestamp appended to archived log files
+const archiveTimeLayout = "20060102-150405"
+
+// archiveSuffix matches what Archive appends to a file name, a counter follows the timestamp
+// when the file was already archived within the same second
+var archiveSuffix = regexp.MustCompile(`^\.(\d{8}-\d{6})(?:-(\d+))?(\.gz)?$`)
+
+// generationSuffix matches what Rotate appends to a file name
+var generationSuffix = regexp.MustCompile(`^\.\d+$`)
+
+// Archive renames a non-empty log file to a timestamped archive, compresses it if configured and prunes old archives
+func Archive(filename string) error {
+	info, err := os.Stat(filename)
+	if err != nil || info.Size() == 0 {
+		return nil
+	}
+
+	config := NewConfiguration()
+	archive := archiveName(filename, time.Now())
+	if err := os.Rename(filename, archive); err != nil {
+		return fmt.Errorf("failed to archive %s: %w", filename, err)
+	}
+
+	if config.Compress {
+		if err := compress(archive); err != nil {
+			log.Printf("Warning: %v", err)
+		}
+	}
+
+	return Prune(filename, config.ArchiveKeep, config.ArchiveMaxAge)
+}
+
+// archiveName returns an unused archive name, a supervisor restarting a service archives its logs
+// several times within a second
+func archiveName(filename string, now time.Time) string {
+	stamp := now.Format(archiveTimeLayout)
+
+	next := 0
+	for _, existing := range Archives(filename) {
+		existingStamp, counter, _ := parseArchive(filename, existing)
+		if existingStamp == stamp && counter >= next {
+			next = counter + 1
+		}
+	}
+
+	if next == 0 {
+		return filename + "." + stamp
+	}
+	return fmt.Sprintf("%s.%s-%d", filename, stamp, next)
+}
+
+// parseArchive returns the timestamp and counter of an archive of filename
+func parseArchive(filename, archive string) (string, int, bool) {
+	match := archiveSuffix.FindStringSubmatch(strings.TrimPrefix(archive, filename))
+	if match == nil {
+		return "", 0, false
+	}
+
+	counter, _ := strconv.Atoi(match[2])
+	return match[1], counter, true
+}
+
+// compress replaces a file with its gzip version
+func compress(filename string) error {
+	source, err := os.Open(filename)
+	if err != nil {
+		return fmt.Errorf("failed to compress %s: %w", filename, err)
+	}
+	defer source.Close()
+
+	target, err := os.OpenFile(filename+".gz", os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to compress %s: %w", filename, err)
+	}
+
+	writer := gzip.NewWriter(target)
+	_, err = io.Copy(writer, source)
+	if closeErr := writer.Close(); err == nil {
+		err = closeErr
+	}
+	if closeErr := target.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(filename + ".gz")
+		return fmt.Errorf("failed to compress %s: %w", filename, err)
+	}
+
+	source.Close()
+	return os.Remove(filename)
+}
+
+// Archives returns the timestamped archives of a log file, newest first
+func Archives(filename string) []string {
+	type entry struct {
+		file    string
+		stamp   string
+		counter int
+	}
+
+	var entries []entry
+	for _, file := range siblings(filename) {
+		if stamp, counter, ok := parseArchive(filename, file); ok {
+			entries = append(entries, entry{file: file, stamp: stamp, counter: counter})
+		}
+	}
+
+	// The timestamp sorts chronologically, the counter orders archives of the same second
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].stamp != entries[j].stamp {
+			return entries[i].stamp > entries[j].stamp
+		}
+		return entries[i].counter > entries[j].counter
+	})
+
+	archives := make([]string, 0, len(entries))
+	for _, e := range entries {
+		archives = append(archives, e.file)
+	}
+	return archives
+}
+
+// Prune removes archives beyond the newest keep ones and archives older than maxAge, zero disables a limit
+func Prune(filename string, keep int, maxAge time.Duration) error {
+	for i, archive := range Archives(filename) {
+		expired := false
+		if maxAge > 0 {
+			stamp, _, _ := parseArchive(filename, archive)
+			if created, err := time.ParseInLocation(archiveTimeLayout, stamp, time.Local); err == nil {
+				expired = time.Since(created) > maxAge
+			}
+		}
+
+		if (keep > 0 && i >= keep) || expired {
+			if err := os.Remove(archive); err != nil {
+				return fmt.Errorf("failed to remove old log %s: %w", archive, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// Clear empties a log file and removes its rotated files and archives
+func Clear(filename string) error {
+	// Truncating keeps the file usable for a process that has it open
+	if err := os.Truncate(filename, 0); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to clear %s: %w", filename, err)
+	}
+
+	for _, file := range siblings(filename) {
+		suffix := strings.TrimPrefix(file, filename)
+		if !archiveSuffix.MatchString(suffix) && !generationSuffix.MatchString(suffix) {
+			continue
+		}
+		if err := os.Remove(file); err != nil {
+			return fmt.Errorf("failed to remove %s: %w", file, err)
+		}
+	}
+
+	return nil
+}
+
+// siblings returns the files whose name starts with the name of filename followed by a dot
+func siblings(filename string) []string {
+	matches, _ := filepath.Glob(escapeGlob(filename) + ".*")
+	return matches
+}
+
+// escapeGlob escapes glob meta characters in a path. Backslashes are separators on Windows,
+// so the characters are wrapped in a class instead, which works on every platform
+func escapeGlob(path string) string {
+	return strings.NewReplacer("*", "[*]", "?", "[?]", "[", "[[]").Replace(path)
+}
@@ -0,0 +1,149 @@
+package logs
+
+import (
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPrune(t *testing.T) {
+	now := time.Now()
+	stamp := func(age time.Duration) string {
+		return now.Add(-age).Format(archiveTimeLayout)
+	}
+	archives := []string{
+		"error.log." + stamp(1*time.Hour) + "-1.gz",
+		"error.log." + stamp(1*time.Hour) + ".gz",
+		"error.log." + stamp(2*24*time.Hour),
+		"error.log." + stamp(5*24*time.Hour) + ".gz",
+		"error.log." + stamp(10*24*time.Hour) + ".gz",
+	}
+
+	tests := []struct {
+		name   string
+		keep   int
+		maxAge time.Duration
+		want   []string
+	}{
+		{name: "no limits", want: archives},
+		{name: "keep newest", keep: 2, want: archives[:2]},
+		{name: "max age", maxAge: 3 * 24 * time.Hour, want: archives[:3]},
+		{name: "both limits", keep: 1, maxAge: 7 * 24 * time.Hour, want: archives[:1]},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			filename := filepath.Join(dir, "error.log")
+			// The current file, rotated output and other logs are never pruned
+			others := []string{"error.log", "error.log.1", "access.log." + stamp(20*24*time.Hour)}
+			for _, name := range append(slices.Clone(archives), others...) {
+				if err := os.WriteFile(filepath.Join(dir, name), []byte("line\n"), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			if err := Prune(filename, tt.keep, tt.maxAge); err != nil {
+				t.Fatal(err)
+			}
+
+			var got []string
+			for _, archive := range Archives(filename) {
+				got = append(got, filepath.Base(archive))
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("archives = %v, want %v", got, tt.want)
+			}
+			for _, name := range others {
+				if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
+					t.Errorf("%s was removed", name)
+				}
+			}
+		})
+	}
+}
+
+func TestArchive(t *testing.T) {
+	tests := []struct {
+		name     string
+		compress string
+		// dir is created inside the temporary folder and holds the log file
+		dir string
+	}{
+		{name: "compressed", compress: "true"},
+		{name: "plain", compress: "false"},
+		{name: "glob characters in the path", compress: "true", dir: "logs [1]"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("LOG_ARCHIVE_GZIP", tt.compress)
+			t.Setenv("LOG_ARCHIVE_KEEP", "10")
+			t.Setenv("LOG_ARCHIVE_DAYS", "0")
+			dir := filepath.Join(t.TempDir(), tt.dir)
+			if err := os.MkdirAll(dir, 0755); err != nil {
+				t.Fatal(err)
+			}
+			filename := filepath.Join(dir, "error.log")
+
+			// A service restarted by the supervisor archives its log several times within a second
+			for _, content := range []string{"first\n", "second\n", "third\n"} {
+				if err := os.WriteFile(filename, []byte(content), 0644); err != nil {
+					t.Fatal(err)
+				}
+				if err := Archive(filename); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			archives := Archives(filename)
+			if len(archives) != 3 {
+				t.Fatalf("Archives() = %v, want 3 archives", archives)
+			}
+			for i, want := range []string{"third\n", "second\n", "first\n"} {
+				if got := readArchive(t, archives[i]); got != want {
+					t.Errorf("archive %s = %q, want %q", filepath.Base(archives[i]), got, want)
+				}
+			}
+
+			if err := Clear(filename); err != nil {
+				t.Fatal(err)
+			}
+			if archives := Archives(filename); len(archives) != 0 {
+				t.Errorf("Archives() after Clear() = %v, want none", archives)
+			}
+		})
+	}
+}
+
+// readArchive returns the content of a plain or compressed archive
+func readArchive(t *testing.T, filename string) string {
+	t.Helper()
+
+	file, err := os.Open(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	var reader io.Reader = file
+	if strings.HasSuffix(filename, ".gz") {
+		gz, err := gzip.NewReader(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer gz.Close()
+		reader = gz
+	}
+
+	content, err := io.ReadAll(reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(content)
+}
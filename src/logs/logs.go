@@ -0,0 +1,179 @@
+package logs
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/alexivashchenko/go-dev-server/helpers"
+	"github.com/alexivashchenko/go-dev-server/launcher"
+)
+
+// OutputFileName is the file in logs/<service>/ holding the captured stdout and stderr of a service
+const OutputFileName = "output.log"
+
+// Configuration holds the log settings
+type Configuration struct {
+	Dir string
+	// MaxSize is the size in bytes after which an output file is rotated
+	MaxSize int64
+	// Keep is the number of rotated output files kept next to the current one
+	Keep int
+	// ArchiveKeep is the number of timestamped archives kept per log file
+	ArchiveKeep int
+	// ArchiveMaxAge removes archives older than this, zero keeps them regardless of age
+	ArchiveMaxAge time.Duration
+	// Compress gzips archived log files
+	Compress bool
+}
+
+// NewConfiguration creates a new log configuration
+func NewConfiguration() *Configuration {
+	return &Configuration{
+		Dir:     filepath.Join(helpers.GetRootDirectory(), "logs"),
+		MaxSize: int64(helpers.GetEnvInt("LOG_MAX_SIZE", 10)) * 1024 * 1024,
+		Keep:    helpers.GetEnvInt("LOG_KEEP", 5),
+
+		ArchiveKeep:   helpers.GetEnvInt("LOG_ARCHIVE_KEEP", 10),
+		ArchiveMaxAge: time.Duration(helpers.GetEnvInt("LOG_ARCHIVE_DAYS", 0)) * 24 * time.Hour,
+		Compress:      helpers.GetEnvBool("LOG_ARCHIVE_GZIP", true),
+	}
+}
+
+// ServiceDir returns the log directory of a service
+func ServiceDir(service string) string {
+	return filepath.Join(NewConfiguration().Dir, strings.ToLower(service))
+}
+
+// OutputFile returns the file the output of a service is captured in
+func OutputFile(service string) string {
+	return filepath.Join(ServiceDir(service), OutputFileName)
+}
+
+// Capture sends the output of a process to the output file of a service, rotating it first when it is too large.
+// The process writes to the file directly, so the file is not rotated again while it runs
+func Capture(spec launcher.Spec, service string) launcher.Spec {
+	config := NewConfiguration()
+	filename := OutputFile(service)
+
+	if err := RotateIfLarger(filename, config.MaxSize, config.Keep); err != nil {
+		log.Printf("Warning: Failed to rotate %s: %v", filename, err)
+	}
+
+	spec.Stdout = filename
+	spec.Stderr = filename
+	return spec
+}
+
+// RotateIfLarger rotates a file once it reached maxSize bytes
+func RotateIfLarger(filename string, maxSize int64, keep int) error {
+	info, err := os.Stat(filename)
+	if err != nil || maxSize <= 0 || info.Size() < maxSize {
+		return nil
+	}
+
+	return Rotate(filename, keep)
+}
+
+// Rotate renames file to file.1, shifting older generations and dropping the ones beyond keep
+func Rotate(filename string, keep int) error {
+	if keep <= 0 {
+		return helpers.RemoveFile(filename)
+	}
+
+	os.Remove(fmt.Sprintf("%s.%d", filename, keep))
+	for i := keep - 1; i >= 1; i-- {
+		older := fmt.Sprintf("%s.%d", filename, i)
+		if _, err := os.Stat(older); err == nil {
+			if err := os.Rename(older, fmt.Sprintf("%s.%d", filename, i+1)); err != nil {
+				return fmt.Errorf("failed to rotate %s: %w", older, err)
+			}
+		}
+	}
+
+	if err := os.Rename(filename, filename+".1"); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to rotate %s: %w", filename, err)
+	}
+
+	return nil
+}
+
+// RotatingWriter appends to a file and rotates it when it grows beyond the configured size
+type RotatingWriter struct {
+	mu       sync.Mutex
+	filename string
+	maxSize  int64
+	keep     int
+	file     *os.File
+	size     int64
+}
+
+// OpenRotating opens a rotating writer on filename using the configured size and generations
+func OpenRotating(filename string) (*RotatingWriter, error) {
+	config := NewConfiguration()
+	writer := &RotatingWriter{
+		filename: filename,
+		maxSize:  config.MaxSize,
+		keep:     config.Keep,
+	}
+
+	if err := writer.open(); err != nil {
+		return nil, err
+	}
+
+	return writer, nil
+}
+
+// open opens the current file for appending
+func (w *RotatingWriter) open() error {
+	if err := os.MkdirAll(filepath.Dir(w.filename), 0755); err != nil {
+		return fmt.Errorf("failed to create log directory for %s: %w", w.filename, err)
+	}
+
+	file, err := os.OpenFile(w.filename, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open log file %s: %w", w.filename, err)
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("failed to open log file %s: %w", w.filename, err)
+	}
+
+	w.file = file
+	w.size = info.Size()
+	return nil
+}
+
+// Write appends p to the file, rotating it first when p doesn't fit anymore
+func (w *RotatingWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.maxSize > 0 && w.size > 0 && w.size+int64(len(p)) > w.maxSize {
+		w.file.Close()
+		if err := Rotate(w.filename, w.keep); err != nil {
+			log.Printf("Warning: %v", err)
+		}
+		if err := w.open(); err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := w.file.Write(p)
+	w.size += int64(n)
+	return n, err
+}
+
+// Close closes the current file
+func (w *RotatingWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	return w.file.Close()
+}
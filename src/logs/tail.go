@@ -0,0 +1,307 @@
+package logs
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Source is a log file shown by the logs command
+type Source struct {
+	Service string
+	File    string
+}
+
+// Label returns the prefix printed in front of every line of the source
+func (s Source) Label() string {
+	return strings.ToLower(s.Service) + "/" + strings.TrimSuffix(filepath.Base(s.File), ".log")
+}
+
+// Options controls which lines are shown
+type Options struct {
+	// Lines is the number of lines shown per source when Since is not set
+	Lines  int
+	Since  time.Time
+	Grep   *regexp.Regexp
+	Follow bool
+	Color  bool
+}
+
+// followInterval is the delay between two checks for new lines when following
+const followInterval = 250 * time.Millisecond
+
+// colors are the ANSI colours assigned to services in order of appearance
+var colors = []string{"36", "33", "35", "32", "34", "31"}
+
+// entry is a single log line with the time it was written, if known
+type entry struct {
+	source int
+	time   time.Time
+	text   string
+}
+
+// Show prints the recent lines of all sources interleaved by time, then follows them if requested
+func Show(sources []Source, options Options) error {
+	printer := newPrinter(sources, options.Color)
+
+	var entries []entry
+	offsets := make([]int64, len(sources))
+	for i, source := range sources {
+		lines, offset, err := readLines(source.File, options)
+		if err != nil {
+			return err
+		}
+		offsets[i] = offset
+
+		// Lines without a timestamp, like stack traces, belong to the line before them
+		var last time.Time
+		for _, line := range lines {
+			if timestamp, ok := ParseTime(line); ok {
+				last = timestamp
+			}
+			if !options.Since.IsZero() && last.Before(options.Since) {
+				continue
+			}
+			if options.Grep != nil && !options.Grep.MatchString(line) {
+				continue
+			}
+			entries = append(entries, entry{source: i, time: last, text: line})
+		}
+	}
+
+	sort.SliceStable(entries, func(a, b int) bool {
+		return entries[a].time.Before(entries[b].time)
+	})
+	for _, e := range entries {
+		printer.print(e.source, e.text)
+	}
+
+	if !options.Follow {
+		return nil
+	}
+
+	// Poll the files, a file that got smaller was rotated or cleared and is read from the start
+	partial := make([]string, len(sources))
+	for {
+		time.Sleep(followInterval)
+
+		for i, source := range sources {
+			info, err := os.Stat(source.File)
+			if err != nil {
+				continue
+			}
+			if info.Size() < offsets[i] {
+				offsets[i], partial[i] = 0, ""
+			}
+			if info.Size() == offsets[i] {
+				continue
+			}
+
+			data, err := readFrom(source.File, offsets[i])
+			if err != nil {
+				continue
+			}
+			offsets[i] += int64(len(data))
+
+			// Only complete lines are printed, the rest waits for the next poll
+			lines := strings.Split(partial[i]+string(data), "\n")
+			partial[i] = lines[len(lines)-1]
+			for _, line := range lines[:len(lines)-1] {
+				line = strings.TrimRight(line, "\r")
+				if options.Grep != nil && !options.Grep.MatchString(line) {
+					continue
+				}
+				printer.print(i, line)
+			}
+		}
+	}
+}
+
+// readLines returns the lines of a file to show initially and the offset following from there
+func readLines(filename string, options Options) ([]string, int64, error) {
+	file, err := os.Open(filename)
+	if os.IsNotExist(err) {
+		return nil, 0, nil
+	}
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to open %s: %w", filename, err)
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to read %s: %w", filename, err)
+	}
+	size := info.Size()
+
+	// Without --since only the end of the file is of interest
+	var start int64
+	if options.Since.IsZero() {
+		start = tailOffset(file, size, options.Lines)
+	}
+
+	if _, err := file.Seek(start, io.SeekStart); err != nil {
+		return nil, 0, fmt.Errorf("failed to read %s: %w", filename, err)
+	}
+
+	var lines []string
+	scanner := bufio.NewScanner(io.LimitReader(file, size-start))
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		lines = append(lines, strings.TrimRight(scanner.Text(), "\r"))
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, 0, fmt.Errorf("failed to read %s: %w", filename, err)
+	}
+
+	if options.Since.IsZero() && len(lines) > options.Lines {
+		lines = lines[len(lines)-options.Lines:]
+	}
+
+	return lines, size, nil
+}
+
+// tailOffset returns an offset close to the start of the last n lines, reading backwards in blocks
+func tailOffset(file *os.File, size int64, n int) int64 {
+	const blockSize = 64 * 1024
+
+	buffer := make([]byte, blockSize)
+	newlines := 0
+	for offset := size; offset > 0; {
+		length := int64(blockSize)
+		if offset < length {
+			length = offset
+		}
+		offset -= length
+
+		if _, err := file.ReadAt(buffer[:length], offset); err != nil && err != io.EOF {
+			return 0
+		}
+		for i := length - 1; i >= 0; i-- {
+			if buffer[i] != '\n' {
+				continue
+			}
+			// The trailing newline of the file doesn't start a line
+			newlines++
+			if newlines > n {
+				return offset + i + 1
+			}
+		}
+	}
+
+	return 0
+}
+
+// readFrom returns the content of a file from offset to the end
+func readFrom(filename string, offset int64) ([]byte, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	if _, err := file.Seek(offset, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	return io.ReadAll(file)
+}
+
+// timeFormats are the timestamps written by the services and the layouts to parse them
+var timeFormats = []struct {
+	pattern *regexp.Regexp
+	layout  string
+}{
+	// MySQL
+	{regexp.MustCompile(`^(\d{4}-\d{2}-\d{2}T\d{2}:\d{2}:\d{2}(?:\.\d+)?(?:Z|[+-]\d{2}:\d{2}))`), time.RFC3339Nano},
+	// Nginx access log
+	{regexp.MustCompile(`\[(\d{2}/\w{3}/\d{4}:\d{2}:\d{2}:\d{2} [+-]\d{4})\]`), "02/Jan/2006:15:04:05 -0700"},
+	// PHP error log, the zone is handled separately
+	{regexp.MustCompile(`^\[(\d{2}-\w{3}-\d{4} \d{2}:\d{2}:\d{2})`), "02-Jan-2006 15:04:05"},
+	// Nginx error log, Go log output and Mailpit
+	{regexp.MustCompile(`^\D{0,10}(\d{4}/\d{2}/\d{2} \d{2}:\d{2}:\d{2})`), "2006/01/02 15:04:05"},
+}
+
+// phpZone matches the time zone name PHP writes after the time
+var phpZone = regexp.MustCompile(`^\[\d{2}-\w{3}-\d{4} \d{2}:\d{2}:\d{2} ([\w/+-]+)\]`)
+
+// ParseTime returns the time a log line was written, if the line starts with a known timestamp
+func ParseTime(line string) (time.Time, bool) {
+	for _, format := range timeFormats {
+		match := format.pattern.FindStringSubmatch(line)
+		if match == nil {
+			continue
+		}
+
+		location := time.Local
+		if zone := phpZone.FindStringSubmatch(line); zone != nil && format.layout == "02-Jan-2006 15:04:05" {
+			if loaded, err := time.LoadLocation(zone[1]); err == nil {
+				location = loaded
+			}
+		}
+
+		if timestamp, err := time.ParseInLocation(format.layout, match[1], location); err == nil {
+			return timestamp, true
+		}
+	}
+
+	return time.Time{}, false
+}
+
+// ParseSince parses --since as a duration like "10m" or a local date and time
+func ParseSince(value string) (time.Time, error) {
+	if duration, err := time.ParseDuration(value); err == nil {
+		return time.Now().Add(-duration), nil
+	}
+
+	for _, layout := range []string{time.RFC3339, "2006-01-02T15:04:05", "2006-01-02 15:04:05", "2006-01-02T15:04", "2006-01-02 15:04", "2006-01-02"} {
+		if timestamp, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+			return timestamp, nil
+		}
+	}
+
+	return time.Time{}, fmt.Errorf("invalid --since value %q, use a duration like 10m or a date like 2006-01-02 15:04", value)
+}
+
+// printer writes lines with an aligned, coloured prefix per source
+type printer struct {
+	labels []string
+	colors []string
+	width  int
+	color  bool
+}
+
+// newPrinter assigns one colour per service
+func newPrinter(sources []Source, color bool) *printer {
+	p := &printer{color: color}
+
+	byService := make(map[string]string)
+	for _, source := range sources {
+		label := source.Label()
+		if len(label) > p.width {
+			p.width = len(label)
+		}
+		if _, ok := byService[source.Service]; !ok {
+			byService[source.Service] = colors[len(byService)%len(colors)]
+		}
+		p.labels = append(p.labels, label)
+		p.colors = append(p.colors, byService[source.Service])
+	}
+
+	return p
+}
+
+// print writes one line of a source
+func (p *printer) print(source int, line string) {
+	label := fmt.Sprintf("%-*s |", p.width, p.labels[source])
+	if p.color {
+		label = "\033[" + p.colors[source] + "m" + label + "\033[0m"
+	}
+	fmt.Println(label, line)
+}
@@ -0,0 +1,110 @@
+package logs
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseTime(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Skip("time zone database not available")
+	}
+
+	tests := []struct {
+		name   string
+		line   string
+		want   time.Time
+		wantOK bool
+	}{
+		{
+			name:   "mysql",
+			line:   "2025-01-31T14:00:05.123456Z 0 [System] [MY-010116] [Server] mysqld starting",
+			want:   time.Date(2025, 1, 31, 14, 0, 5, 123456000, time.UTC),
+			wantOK: true,
+		},
+		{
+			name:   "nginx access log",
+			line:   `127.0.0.1 - - [31/Jan/2025:14:00:05 +0100] "GET / HTTP/1.1" 200 612`,
+			want:   time.Date(2025, 1, 31, 13, 0, 5, 0, time.UTC),
+			wantOK: true,
+		},
+		{
+			name:   "php error log with zone",
+			line:   "[31-Jan-2025 14:00:05 Europe/Berlin] PHP Warning:  Undefined variable $a",
+			want:   time.Date(2025, 1, 31, 14, 0, 5, 0, berlin),
+			wantOK: true,
+		},
+		{
+			name:   "php error log with unknown zone",
+			line:   "[31-Jan-2025 14:00:05 Nowhere/City] PHP Notice:  test",
+			want:   time.Date(2025, 1, 31, 14, 0, 5, 0, time.Local),
+			wantOK: true,
+		},
+		{
+			name:   "nginx error log",
+			line:   "2025/01/31 14:00:05 [error] 1234#5678: *1 open() failed",
+			want:   time.Date(2025, 1, 31, 14, 0, 5, 0, time.Local),
+			wantOK: true,
+		},
+		{
+			name:   "mailpit with level prefix",
+			line:   "INFO[2025/01/31 14:00:05] [smtpd] starting on [::]:1025",
+			want:   time.Date(2025, 1, 31, 14, 0, 5, 0, time.Local),
+			wantOK: true,
+		},
+		{name: "without timestamp", line: "PHP Fatal error:  Uncaught Exception", wantOK: false},
+		{name: "invalid date", line: "2025/13/45 14:00:05 [error] test", wantOK: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := ParseTime(tt.line)
+			if ok != tt.wantOK {
+				t.Fatalf("ParseTime() ok = %t, want %t", ok, tt.wantOK)
+			}
+			if ok && !got.Equal(tt.want) {
+				t.Errorf("ParseTime() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseSince(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    time.Time
+		wantErr bool
+	}{
+		{value: "2025-01-31 14:00", want: time.Date(2025, 1, 31, 14, 0, 0, 0, time.Local)},
+		{value: "2025-01-31T14:00:05", want: time.Date(2025, 1, 31, 14, 0, 5, 0, time.Local)},
+		{value: "2025-01-31", want: time.Date(2025, 1, 31, 0, 0, 0, 0, time.Local)},
+		{value: "2025-01-31T14:00:05Z", want: time.Date(2025, 1, 31, 14, 0, 5, 0, time.UTC)},
+		{value: "yesterday", wantErr: true},
+		{value: "10", wantErr: true},
+		{value: "31.01.2025", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, err := ParseSince(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseSince(%q) error = %v, wantErr %v", tt.value, err, tt.wantErr)
+			}
+			if !tt.wantErr && !got.Equal(tt.want) {
+				t.Errorf("ParseSince(%q) = %v, want %v", tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseSinceDuration(t *testing.T) {
+	got, err := ParseSince("10m")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if want := time.Now().Add(-10 * time.Minute); got.Sub(want).Abs() > time.Second {
+		t.Errorf("ParseSince(10m) = %v, want about %v", got, want)
+	}
+}
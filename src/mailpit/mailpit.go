@@ -3,12 +3,17 @@ package mailpit
 import (
 	"fmt"
 	"log"
+	"net"
 	"os"
 	"path/filepath"
 	"runtime"
 	"time"
 
+	"github.com/alexivashchenko/go-dev-server/health"
 	"github.com/alexivashchenko/go-dev-server/helpers"
+	"github.com/alexivashchenko/go-dev-server/launcher"
+	"github.com/alexivashchenko/go-dev-server/logs"
+	"github.com/alexivashchenko/go-dev-server/status"
 )
 
 // Configuration holds all Mailpit-related settings
@@ -17,6 +22,8 @@ type Configuration struct {
 	AppFolder      string
 	ExecutableName string
 	AppPath        string
+	PIDFile        string
+	ReadyTimeout   time.Duration
 	SMTPHost       string
 	SMTPPort       string
 	UIHost         string
@@ -74,10 +81,12 @@ func NewConfiguration() (*Configuration, error) {
 		SMTPPort:       smtpPort,
 		UIHost:         uiHost,
 		UIPort:         uiPort,
+		ReadyTimeout:   helpers.GetEnvDuration("MAILPIT_READY_TIMEOUT", 10*time.Second),
 	}
 
 	// Set paths
 	config.AppPath = filepath.Join(rootDir, "apps", "mailpit", mailpitAppFolder)
+	config.PIDFile = helpers.GetPIDFilePath("mailpit")
 
 	return config, nil
 }
@@ -93,6 +102,11 @@ func Start() error {
 		return fmt.Errorf("failed to initialize mailpit configuration: %w", err)
 	}
 
+	if pids, _ := helpers.GetRunningPIDs(config.PIDFile); len(pids) > 0 {
+		log.Printf("Mailpit is already running (PID: %d)", pids[0])
+		return nil
+	}
+
 	// Start Mailpit
 	if err := startMailpit(config); err != nil {
 		return fmt.Errorf("failed to start mailpit: %w", err)
@@ -103,6 +117,26 @@ func Start() error {
 	return nil
 }
 
+// Process returns the mailpit command to run in the foreground
+func Process() (launcher.Spec, error) {
+	config, err := NewConfiguration()
+	if err != nil {
+		return launcher.Spec{}, fmt.Errorf("failed to initialize mailpit configuration: %w", err)
+	}
+
+	return mailpitCommand(config), nil
+}
+
+// Ready waits until the Mailpit SMTP server and web UI answer
+func Ready() error {
+	config, err := NewConfiguration()
+	if err != nil {
+		return fmt.Errorf("failed to initialize mailpit configuration: %w", err)
+	}
+
+	return verifyMailpitRunning(config)
+}
+
 // Stop stops the Mailpit service
 func Stop() error {
 	log.Println("Stopping Mailpit service...")
@@ -113,8 +147,8 @@ func Stop() error {
 		return fmt.Errorf("failed to initialize mailpit configuration: %w", err)
 	}
 
-	// Kill Mailpit process
-	if err := helpers.KillProcess(config.ExecutableName); err != nil {
+	// Stop the recorded Mailpit process
+	if err := helpers.StopPIDFile(config.PIDFile, 5*time.Second); err != nil {
 		return fmt.Errorf("failed to stop mailpit process: %w", err)
 	}
 
@@ -143,44 +177,44 @@ func Restart() error {
 }
 
 // GetStatus returns the current status of the Mailpit service
-func GetStatus() string {
+func GetStatus() status.Status {
 	config, err := NewConfiguration()
 	if err != nil {
-		return "Error: " + err.Error()
+		return status.FromError(err)
 	}
 
-	running, pid := helpers.IsProcessRunning(config.ExecutableName)
-	if running {
-		return fmt.Sprintf("Running (PID: %d, SMTP: %s:%s, UI: http://%s:%s)",
-			pid, config.SMTPHost, config.SMTPPort, config.UIHost, config.UIPort)
+	result := status.FromPIDFile(config.PIDFile)
+	result.Addresses = []string{
+		net.JoinHostPort(config.SMTPHost, config.SMTPPort),
+		net.JoinHostPort(config.UIHost, config.UIPort),
 	}
-	return "Stopped"
+	result.Version = status.Version(filepath.Join(config.AppPath, config.ExecutableName), "version")
+	result.Details = fmt.Sprintf("UI: http://%s", net.JoinHostPort(config.UIHost, config.UIPort))
+	result.CheckHealth(readinessProbe(config))
+
+	return result
 }
 
 // startMailpit starts the Mailpit server
 func startMailpit(config *Configuration) error {
 	log.Println("Starting Mailpit server...")
 
-	// Build command with all necessary parameters
-	command := fmt.Sprintf("%s --smtp=%s:%s --listen=%s:%s",
-		filepath.Join(config.AppPath, config.ExecutableName),
-		config.SMTPHost,
-		config.SMTPPort,
-		config.UIHost,
-		config.UIPort,
-	)
-
-	log.Printf("Running command: %s", command)
+	spec := mailpitCommand(config)
+	log.Printf("Running command: %s", spec)
 
-	if err := helpers.RunCommand(command, true); err != nil {
+	// Start Mailpit and verify it keeps running
+	cmd, err := launcher.StartBackground(spec, 500*time.Millisecond)
+	if err != nil {
 		return fmt.Errorf("failed to start mailpit: %w", err)
 	}
 
-	// Verify process is running
-	time.Sleep(500 * time.Millisecond) // Give process time to start
-	running, _ := helpers.IsProcessRunning(config.ExecutableName)
-	if !running {
-		return fmt.Errorf("mailpit process failed to start")
+	if err := helpers.WritePIDFile(config.PIDFile, cmd.Process.Pid); err != nil {
+		return fmt.Errorf("failed to record mailpit PID: %w", err)
+	}
+
+	// Wait until SMTP and the web UI answer
+	if err := verifyMailpitRunning(config); err != nil {
+		return err
 	}
 
 	log.Printf("Mailpit started with SMTP on %s:%s and UI on https://%s:%s",
@@ -188,3 +222,32 @@ func startMailpit(config *Configuration) error {
 
 	return nil
 }
+
+// verifyMailpitRunning checks the SMTP port and the web UI until both answer
+func verifyMailpitRunning(config *Configuration) error {
+	if err := health.WaitFor(readinessProbe(config), config.ReadyTimeout); err != nil {
+		return fmt.Errorf("mailpit is not answering: %w", err)
+	}
+
+	return nil
+}
+
+// readinessProbe checks that the recorded mailpit is alive and both SMTP and the web UI answer
+func readinessProbe(config *Configuration) health.Probe {
+	return health.All(
+		health.PIDFile(config.PIDFile),
+		health.TCP(net.JoinHostPort(config.SMTPHost, config.SMTPPort)),
+		health.HTTP(fmt.Sprintf("http://%s/", net.JoinHostPort(config.UIHost, config.UIPort))),
+	)
+}
+
+// mailpitCommand returns the process running Mailpit in the foreground
+func mailpitCommand(config *Configuration) launcher.Spec {
+	return logs.Capture(launcher.Spec{
+		Executable: filepath.Join(config.AppPath, config.ExecutableName),
+		Args: []string{
+			fmt.Sprintf("--smtp=%s:%s", config.SMTPHost, config.SMTPPort),
+			fmt.Sprintf("--listen=%s:%s", config.UIHost, config.UIPort),
+		},
+	}, "mailpit")
+}
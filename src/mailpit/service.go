@@ -0,0 +1,49 @@
+package mailpit
+
+import (
+	"github.com/alexivashchenko/go-dev-server/launcher"
+	"github.com/alexivashchenko/go-dev-server/status"
+)
+
+// Service exposes the Mailpit package to the service registry
+type Service struct{}
+
+// Name returns the service name used on the command line and in .env
+func (Service) Name() string {
+	return "Mailpit"
+}
+
+// DependsOn returns the services that must be started before Mailpit
+func (Service) DependsOn() []string {
+	return nil
+}
+
+// Start starts the Mailpit service
+func (Service) Start() error {
+	return Start()
+}
+
+// Stop stops the Mailpit service
+func (Service) Stop() error {
+	return Stop()
+}
+
+// Restart restarts the Mailpit service
+func (Service) Restart() error {
+	return Restart()
+}
+
+// Status returns the current status of the Mailpit service
+func (Service) Status() status.Status {
+	return GetStatus()
+}
+
+// Process returns the foreground process used by run mode
+func (Service) Process() (launcher.Spec, error) {
+	return Process()
+}
+
+// Ready waits until Mailpit accepts requests
+func (Service) Ready() error {
+	return Ready()
+}
@@ -1,9 +1,13 @@
 package main
 
 import (
+	"encoding/json"
 	"fmt"
 	"os"
+	"strconv"
+	"strings"
 	"sync"
+	"text/tabwriter"
 	"time"
 
 	"github.com/alexivashchenko/go-dev-server/env"
@@ -12,79 +16,108 @@ import (
 	"github.com/alexivashchenko/go-dev-server/mysql"
 	"github.com/alexivashchenko/go-dev-server/nginx"
 	"github.com/alexivashchenko/go-dev-server/php"
+	"github.com/alexivashchenko/go-dev-server/service"
 	"github.com/alexivashchenko/go-dev-server/ssl"
+	"github.com/alexivashchenko/go-dev-server/status"
 )
 
-// Service represents a server component that can be started, stopped, and restarted
-type Service struct {
-	Name     string
-	Start    func() error
-	Stop     func() error
-	Restart  func() error
-	GetState func() string
-}
-
 func main() {
+	// Register services, each one can be disabled with <NAME>_ENABLED=false in .env
+	service.Register(mysql.Service{})
+	service.Register(ssl.Service{})
+	service.Register(php.Service{})
+	service.Register(nginx.Service{})
+	service.Register(mailpit.Service{})
+
+	// Get command, service names and flags from arguments
+	command, args, commandErr := helpers.GetCommand()
+	names, flags := helpers.ParseArgs(args)
+
+	// Resolve the root directory before anything reads files from it
+	if root, ok := flags["root"]; ok {
+		if root == "" {
+			fmt.Println("Error: --root needs a directory, use --root=PATH")
+			os.Exit(1)
+		}
+		if err := helpers.SetRootDirectory(root); err != nil {
+			fmt.Printf("Error: %s\n", err)
+			os.Exit(1)
+		}
+	}
+
 	// Load environment variables
 	env.Load()
 
-	// Get command from arguments
-	command, err := helpers.GetCommand()
-	if err != nil {
-		fmt.Printf("Error: %s\n", err)
+	if commandErr != nil {
+		fmt.Printf("Error: %s\n", commandErr)
 		printUsage()
 		os.Exit(1)
 	}
 
-	// Define services
-	services := []Service{
-		{
-			Name:     "MySQL",
-			Start:    mysql.Start,
-			Stop:     mysql.Stop,
-			Restart:  mysql.Restart,
-			GetState: mysql.GetStatus,
-		},
-		{
-			Name:     "SSL",
-			Start:    ssl.Start,
-			Stop:     ssl.Stop,
-			Restart:  ssl.Restart,
-			GetState: ssl.GetStatus,
-		},
-		{
-			Name:     "PHP",
-			Start:    php.Start,
-			Stop:     php.Stop,
-			Restart:  php.Restart,
-			GetState: php.GetStatus,
-		},
-		{
-			Name:     "Nginx",
-			Start:    nginx.Start,
-			Stop:     nginx.Stop,
-			Restart:  nginx.Restart,
-			GetState: nginx.GetStatus,
-		},
-		{
-			Name:     "Mailpit",
-			Start:    mailpit.Start,
-			Stop:     mailpit.Stop,
-			Restart:  mailpit.Restart,
-			GetState: mailpit.GetStatus,
-		},
+	// Site and PHP commands take paths, names and versions instead of service names
+	switch command {
+	case "link":
+		linkSite(names)
+		return
+	case "unlink":
+		unlinkSite(names)
+		return
+	case "links":
+		showLinks()
+		return
+	case "php":
+		phpCommand(names)
+		return
+	case "xdebug":
+		setXdebug(names, flags)
+		return
+	case php.ManagerCommand:
+		// Started by the PHP service, not meant to be run by hand
+		runPHPWorkers()
+		return
+	}
+
+	// "logs clear" is a subcommand, not a service name
+	subcommand := ""
+	if command == "logs" && len(names) > 0 && names[0] == "clear" {
+		subcommand, names = names[0], names[1:]
+	}
+
+	// Starting a service also starts everything it depends on
+	selected, err := service.Select(names, command == "start" || command == "run")
+	if err != nil {
+		fmt.Printf("Error: %s\n", err)
+		printUsage()
+		os.Exit(1)
 	}
 
 	// Process command
 	switch command {
 	case "start":
-		startServices(services)
+		startServices(selected)
 	case "stop":
-		stopServices(services)
+		stopSupervisor(len(names) == 0)
+		stopServices(selected)
 	case "restart":
-		restartServices(services)
+		restartServices(selected)
+	case "reload":
+		reloadServices(selected)
+	case "watch":
+		watchSites(selected)
 	case "status":
-		showStatus(services)
+		showStatus(selected, flags)
+	case "logs":
+		if subcommand == "clear" {
+			clearLogs(selected)
+			return
+		}
+		showLogs(selected, flags)
+	case "run":
+		if flags["detach"] == "true" {
+			detachSupervisor(args)
+			return
+		}
+		runServices(selected)
 	case "help":
 		printUsage()
 	default:
@@ -94,114 +127,287 @@ func main() {
 	}
 }
 
-// startServices starts all services in parallel
-func startServices(services []Service) {
-	fmt.Println("Starting all services...")
+// startServices starts services wave by wave, each wave in parallel
+func startServices(services []service.Service) {
+	fmt.Println("Starting services...")
 
-	var wg sync.WaitGroup
-	errChan := make(chan error, len(services))
+	waves, err := service.Waves(services)
+	if err != nil {
+		fmt.Printf("Error: %s\n", err)
+		os.Exit(1)
+	}
 
-	for _, service := range services {
-		wg.Add(1)
-		go func(s Service) {
-			defer wg.Done()
-			fmt.Printf("Starting %s...\n", s.Name)
+	for _, wave := range waves {
+		errs := runWave(wave, func(s service.Service) error {
+			fmt.Printf("Starting %s...\n", s.Name())
 			start := time.Now()
 			if err := s.Start(); err != nil {
-				errChan <- fmt.Errorf("failed to start %s: %w", s.Name, err)
-				return
+				status.RecordError(s.Name(), err)
+				return fmt.Errorf("failed to start %s: %w", s.Name(), err)
 			}
+			status.RecordError(s.Name(), nil)
 			elapsed := time.Since(start)
-			fmt.Printf("%s started successfully in %.2f seconds\n", s.Name, elapsed.Seconds())
-		}(service)
+			fmt.Printf("%s started successfully in %.2f seconds\n", s.Name(), elapsed.Seconds())
+			return nil
+		})
+
+		// Do not start dependants of a service that failed
+		if len(errs) > 0 {
+			for _, err := range errs {
+				fmt.Println(err)
+			}
+			os.Exit(1)
+		}
 	}
 
-	wg.Wait()
-	close(errChan)
+	fmt.Println("Services started successfully")
+}
 
-	// Check for errors
-	for err := range errChan {
-		fmt.Println(err)
+// stopServices stops services in reverse dependency order, each wave in parallel
+func stopServices(services []service.Service) {
+	fmt.Println("Stopping services...")
+
+	waves, err := service.Waves(services)
+	if err != nil {
+		fmt.Printf("Error: %s\n", err)
 		os.Exit(1)
 	}
 
-	fmt.Println("All services started successfully")
+	for i := len(waves) - 1; i >= 0; i-- {
+		errs := runWave(waves[i], func(s service.Service) error {
+			fmt.Printf("Stopping %s...\n", s.Name())
+			if err := s.Stop(); err != nil {
+				return fmt.Errorf("failed to stop %s: %w", s.Name(), err)
+			}
+			fmt.Printf("%s stopped successfully\n", s.Name())
+			return nil
+		})
+
+		// Stop failures are not fatal, keep going with the next wave
+		for _, err := range errs {
+			fmt.Printf("Warning: %v\n", err)
+		}
+	}
+
+	fmt.Println("Services stopped")
 }
 
-// stopServices stops all services in parallel
-func stopServices(services []Service) {
-	fmt.Println("Stopping all services...")
+// restartServices stops all waves in reverse dependency order and starts them again in dependency order,
+// so no service restarts while the services depending on it are still running
+func restartServices(services []service.Service) {
+	fmt.Println("Restarting services...")
 
-	var wg sync.WaitGroup
-	for _, service := range services {
-		wg.Add(1)
-		go func(s Service) {
-			defer wg.Done()
-			fmt.Printf("Stopping %s...\n", s.Name)
-			if err := s.Stop(); err != nil {
-				fmt.Printf("Warning: Failed to stop %s: %v\n", s.Name, err)
-				return
-			}
-			fmt.Printf("%s stopped successfully\n", s.Name)
-		}(service)
+	stopServices(services)
+	startServices(services)
+}
+
+// reloadServices applies configuration changes to the selected services without stopping them
+func reloadServices(services []service.Service) {
+	fmt.Println("Reloading services...")
+
+	waves, err := service.Waves(services)
+	if err != nil {
+		fmt.Printf("Error: %s\n", err)
+		os.Exit(1)
 	}
 
-	wg.Wait()
-	fmt.Println("All services stopped")
+	if errs := reloadWaves(waves); len(errs) > 0 {
+		for _, err := range errs {
+			fmt.Println(err)
+		}
+		os.Exit(1)
+	}
+
+	fmt.Println("Services reloaded successfully")
 }
 
-// restartServices restarts all services in parallel
-func restartServices(services []Service) {
-	fmt.Println("Restarting all services...")
+// reloadWaves reloads the reloadable services wave by wave and stops at the first wave with errors
+func reloadWaves(waves [][]service.Service) []error {
+	for _, wave := range waves {
+		errs := runWave(wave, func(s service.Service) error {
+			reloadable, ok := s.(service.Reloadable)
+			if !ok {
+				return nil
+			}
+			fmt.Printf("Reloading %s...\n", s.Name())
+			start := time.Now()
+			if err := reloadable.Reload(); err != nil {
+				return fmt.Errorf("failed to reload %s: %w", s.Name(), err)
+			}
+			elapsed := time.Since(start)
+			fmt.Printf("%s reloaded successfully in %.2f seconds\n", s.Name(), elapsed.Seconds())
+			return nil
+		})
+
+		if len(errs) > 0 {
+			return errs
+		}
+	}
 
+	return nil
+}
+
+// runWave runs an action for every service of a wave in parallel and returns the collected errors
+func runWave(wave []service.Service, action func(service.Service) error) []error {
 	var wg sync.WaitGroup
-	errChan := make(chan error, len(services))
+	errChan := make(chan error, len(wave))
 
-	for _, service := range services {
+	for _, s := range wave {
 		wg.Add(1)
-		go func(s Service) {
+		go func(s service.Service) {
 			defer wg.Done()
-			fmt.Printf("Restarting %s...\n", s.Name)
-			start := time.Now()
-			if err := s.Restart(); err != nil {
-				errChan <- fmt.Errorf("failed to restart %s: %w", s.Name, err)
-				return
+			if err := action(s); err != nil {
+				errChan <- err
 			}
-			elapsed := time.Since(start)
-			fmt.Printf("%s restarted successfully in %.2f seconds\n", s.Name, elapsed.Seconds())
-		}(service)
+		}(s)
 	}
 
 	wg.Wait()
 	close(errChan)
 
-	// Check for errors
+	var errs []error
 	for err := range errChan {
-		fmt.Println(err)
-		os.Exit(1)
+		errs = append(errs, err)
+	}
+
+	return errs
+}
+
+// showStatus displays the status of services as a table or JSON, optionally refreshing it
+func showStatus(services []service.Service, flags map[string]string) {
+	watch, interval := flags["watch"] != "", 2*time.Second
+	if seconds, err := strconv.Atoi(flags["watch"]); err == nil && seconds > 0 {
+		interval = time.Duration(seconds) * time.Second
+	}
+
+	for {
+		statuses := collectStatuses(services)
+
+		if watch && flags["json"] == "" {
+			// Clear the terminal before redrawing
+			fmt.Print("\033[H\033[2J")
+			fmt.Printf("Refreshing every %s, press Ctrl+C to stop\n\n", interval)
+		}
+
+		if flags["json"] != "" {
+			output, err := json.MarshalIndent(map[string]any{
+				"root":        helpers.GetRootDirectory(),
+				"root_source": helpers.GetRootDirectorySource(),
+				"services":    statuses,
+			}, "", "  ")
+			if err != nil {
+				fmt.Printf("Error: %s\n", err)
+				os.Exit(1)
+			}
+			fmt.Println(string(output))
+		} else {
+			printStatusTable(statuses)
+		}
+
+		if !watch {
+			return
+		}
+		time.Sleep(interval)
 	}
+}
+
+// collectStatuses queries all services in parallel and keeps their order
+func collectStatuses(services []service.Service) []status.Status {
+	statuses := make([]status.Status, len(services))
 
-	fmt.Println("All services restarted successfully")
+	var wg sync.WaitGroup
+	for i, s := range services {
+		wg.Add(1)
+		go func(i int, s service.Service) {
+			defer wg.Done()
+			result := s.Status()
+			result.Name = s.Name()
+			if result.LastError == "" {
+				result.LastError = status.LastError(s.Name())
+			}
+			if result.PIDs == nil {
+				result.PIDs = []int{}
+			}
+			if result.Addresses == nil {
+				result.Addresses = []string{}
+			}
+			statuses[i] = result
+		}(i, s)
+	}
+	wg.Wait()
+
+	return statuses
 }
 
-// showStatus displays the status of all services
-func showStatus(services []Service) {
+// printStatusTable prints statuses as an aligned table
+func printStatusTable(statuses []status.Status) {
+	fmt.Printf("Root: %s (%s)\n\n", helpers.GetRootDirectory(), helpers.GetRootDirectorySource())
 	fmt.Println("Service Status:")
 	fmt.Println("==============")
 
-	for _, service := range services {
-		state := service.GetState()
-		fmt.Printf("%-10s: %s\n", service.Name, state)
+	writer := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(writer, "SERVICE\tSTATE\tPID\tADDRESSES\tUPTIME\tHEALTH\tVERSION")
+
+	for _, result := range statuses {
+		pids := make([]string, 0, len(result.PIDs))
+		for _, pid := range result.PIDs {
+			pids = append(pids, strconv.Itoa(pid))
+		}
+
+		uptime := "-"
+		if result.State == status.Running {
+			uptime = result.Uptime().String()
+		}
+
+		fmt.Fprintf(writer, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			result.Name,
+			result.State,
+			orDash(strings.Join(pids, ",")),
+			orDash(strings.Join(result.Addresses, ", ")),
+			uptime,
+			orDash(result.Health),
+			orDash(result.Version))
+	}
+	writer.Flush()
+
+	// Errors and details are too long for the table
+	for _, result := range statuses {
+		if result.Details != "" {
+			fmt.Printf("%s: %s\n", result.Name, result.Details)
+		}
+		if result.LastError != "" {
+			fmt.Printf("%s last error: %s\n", result.Name, result.LastError)
+		}
+	}
+}
+
+// orDash returns a dash for empty table cells
+func orDash(value string) string {
+	if value == "" {
+		return "-"
 	}
+	return value
 }
 
 // printUsage prints usage information
 func printUsage() {
-	fmt.Println("Usage: server <command>")
+	fmt.Println("Usage: server [--root=PATH] <command> [service...]")
 	fmt.Println("\nAvailable commands:")
-	fmt.Println("  start    - Start all services")
-	fmt.Println("  stop     - Stop all services")
-	fmt.Println("  restart  - Restart all services")
-	fmt.Println("  status   - Show status of all services")
+	fmt.Println("  start    - Start services (and the services they depend on)")
+	fmt.Println("  stop     - Stop services")
+	fmt.Println("  restart  - Restart services")
+	fmt.Println("  reload   - Apply site changes to the running Nginx without dropping connections")
+	fmt.Println("  watch    - Reload automatically when site folders are added, removed or renamed")
+	fmt.Println("  status   - Show status of services (--json for JSON, --watch[=seconds] to refresh)")
+	fmt.Println("  logs     - Show service logs (-f to follow, --since=10m, --grep=PATTERN, --lines=N)")
+	fmt.Println("  logs clear - Empty service logs and remove their archives")
+	fmt.Println("  link     - Serve a folder outside www/ as a site (server link <path> [name])")
+	fmt.Println("  unlink   - Remove a linked site (server unlink <name>)")
+	fmt.Println("  links    - List linked sites")
+	fmt.Println("  php      - Manage PHP versions (server php list|current|use <version>)")
+	fmt.Println("             and extensions (server php ext list|enable <name>|disable <name>)")
+	fmt.Println("  xdebug   - Turn Xdebug on or off (server xdebug on|off [--mode=debug,coverage])")
+	fmt.Println("  run      - Run services in the foreground and restart them when they crash (--detach to run in background)")
 	fmt.Println("  help     - Show this help message")
+	fmt.Printf("\nServices: %s (all when omitted)\n", strings.Join(service.Names(service.Enabled()), ", "))
 }
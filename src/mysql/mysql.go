@@ -9,7 +9,12 @@ import (
 	"strings"
 	"time"
 
+	"github.com/alexivashchenko/go-dev-server/health"
 	"github.com/alexivashchenko/go-dev-server/helpers"
+	"github.com/alexivashchenko/go-dev-server/launcher"
+	"github.com/alexivashchenko/go-dev-server/logs"
+	"github.com/alexivashchenko/go-dev-server/status"
+	"github.com/alexivashchenko/go-dev-server/templates"
 )
 
 // Configuration holds all MySQL-related settings
@@ -22,8 +27,11 @@ type Configuration struct {
 	TemplatesDir       string
 	ConfigFile         string
 	ConfigTemplateFile string
+	ErrorLog           string
 	ExecutableName     string
+	PIDFile            string
 	Port               int
+	ReadyTimeout       time.Duration
 	User               string
 	Password           string
 }
@@ -57,6 +65,7 @@ func NewConfiguration() (*Configuration, error) {
 		DataFolder:     mysqlDataFolder,
 		ExecutableName: executableName,
 		Port:           3306,
+		ReadyTimeout:   helpers.GetEnvDuration("MYSQL_READY_TIMEOUT", 30*time.Second),
 		User:           "root",
 		Password:       "", // Default password is empty after initialization
 	}
@@ -67,6 +76,8 @@ func NewConfiguration() (*Configuration, error) {
 	config.TemplatesDir = filepath.Join(rootDir, "tpl")
 	config.ConfigFile = filepath.Join(config.AppDir, "my.ini")
 	config.ConfigTemplateFile = filepath.Join(config.TemplatesDir, "mysql", "my.ini.tpl")
+	config.ErrorLog = filepath.Join(logs.ServiceDir("mysql"), "error.log")
+	config.PIDFile = helpers.GetPIDFilePath("mysql")
 
 	// Validate template file exists
 	if _, err := os.Stat(config.ConfigTemplateFile); os.IsNotExist(err) {
@@ -87,21 +98,15 @@ func Start() error {
 		return fmt.Errorf("failed to initialize MySQL configuration: %w", err)
 	}
 
-	// Check if MySQL needs initialization
-	needsInit, err := checkIfNeedsInitialization(config)
-	if err != nil {
-		return fmt.Errorf("failed to check if MySQL needs initialization: %w", err)
+	// Don't start a second server on the same data directory
+	if pids, _ := helpers.GetRunningPIDs(config.PIDFile); len(pids) > 0 {
+		log.Printf("MySQL is already running (PID: %d)", pids[0])
+		return nil
 	}
 
-	// Initialize MySQL if needed
-	if needsInit {
-		log.Println("MySQL data directory not found, initializing MySQL...")
-		if err := initializeMySQL(config); err != nil {
-			return fmt.Errorf("failed to initialize MySQL: %w", err)
-		}
-		log.Println("MySQL initialization completed successfully")
-	} else {
-		log.Println("MySQL data directory exists, skipping initialization")
+	// Initialize data directory if needed
+	if err := prepareMySQL(config); err != nil {
+		return err
 	}
 
 	// Start MySQL server
@@ -119,6 +124,30 @@ func Start() error {
 	return nil
 }
 
+// Process prepares MySQL and returns the mysqld command to run in the foreground
+func Process() (launcher.Spec, error) {
+	config, err := NewConfiguration()
+	if err != nil {
+		return launcher.Spec{}, fmt.Errorf("failed to initialize MySQL configuration: %w", err)
+	}
+
+	if err := prepareMySQL(config); err != nil {
+		return launcher.Spec{}, err
+	}
+
+	return mysqldCommand(config), nil
+}
+
+// Ready waits until MySQL accepts connections
+func Ready() error {
+	config, err := NewConfiguration()
+	if err != nil {
+		return fmt.Errorf("failed to initialize MySQL configuration: %w", err)
+	}
+
+	return verifyMySQLRunning(config)
+}
+
 // Stop stops the MySQL service
 func Stop() error {
 	log.Println("Stopping MySQL service...")
@@ -129,15 +158,9 @@ func Stop() error {
 		return fmt.Errorf("failed to initialize MySQL configuration: %w", err)
 	}
 
-	// Try graceful shutdown first
+	// Shut down gracefully, the process is killed if it takes too long
 	if err := gracefulShutdown(config); err != nil {
-		log.Printf("Warning: Graceful shutdown failed: %v", err)
-		log.Println("Attempting to kill MySQL process...")
-
-		// Fall back to killing the process
-		if err := helpers.KillProcess(config.ExecutableName); err != nil {
-			return fmt.Errorf("failed to stop MySQL process: %w", err)
-		}
+		return fmt.Errorf("failed to stop MySQL process: %w", err)
 	}
 
 	elapsed := time.Since(startTime)
@@ -165,16 +188,40 @@ func Restart() error {
 }
 
 // GetStatus returns the current status of the MySQL service
-func GetStatus() string {
+func GetStatus() status.Status {
 	config, err := NewConfiguration()
 	if err != nil {
-		return "Error: " + err.Error()
+		return status.FromError(err)
+	}
+
+	result := status.FromPIDFile(config.PIDFile)
+	result.Addresses = []string{fmt.Sprintf("127.0.0.1:%d", config.Port)}
+	result.Version = status.Version(filepath.Join(config.AppDir, "bin", config.ExecutableName), "--version")
+	result.CheckHealth(readinessProbe(config))
+
+	return result
+}
+
+// prepareMySQL initializes the MySQL data directory when it is missing or empty
+func prepareMySQL(config *Configuration) error {
+	// Check if MySQL needs initialization
+	needsInit, err := checkIfNeedsInitialization(config)
+	if err != nil {
+		return fmt.Errorf("failed to check if MySQL needs initialization: %w", err)
 	}
-	running, pid := helpers.IsProcessRunning(config.ExecutableName)
-	if running {
-		return fmt.Sprintf("Running (PID: %d, Port: %d)", pid, config.Port)
+
+	// Initialize MySQL if needed
+	if needsInit {
+		log.Println("MySQL data directory not found, initializing MySQL...")
+		if err := initializeMySQL(config); err != nil {
+			return fmt.Errorf("failed to initialize MySQL: %w", err)
+		}
+		log.Println("MySQL initialization completed successfully")
+	} else {
+		log.Println("MySQL data directory exists, skipping initialization")
 	}
-	return "Stopped"
+
+	return nil
 }
 
 // checkIfNeedsInitialization checks if MySQL needs to be initialized
@@ -202,12 +249,7 @@ func initializeMySQL(config *Configuration) error {
 		return fmt.Errorf("failed to create data directory: %w", err)
 	}
 
-	// Copy configuration template
-	if err := helpers.CopyFile(config.ConfigTemplateFile, config.ConfigFile); err != nil {
-		return fmt.Errorf("failed to copy MySQL configuration template: %w", err)
-	}
-
-	// Update configuration with data folder path
+	// Render configuration with data folder path
 	if err := updateMySQLConfig(config); err != nil {
 		return fmt.Errorf("failed to update MySQL configuration: %w", err)
 	}
@@ -215,10 +257,11 @@ func initializeMySQL(config *Configuration) error {
 	// Run MySQL initialization
 	log.Println("Running MySQL initialization...")
 
-	mysqldPath := filepath.Join(config.AppDir, "bin", config.ExecutableName)
-	command := fmt.Sprintf("%s --defaults-file=%s --initialize", mysqldPath, config.ConfigFile)
-
-	output, err := helpers.RunCommandWithOutput(command)
+	// The temporary password is read from the output, so the error log is not redirected here
+	output, err := launcher.Output(launcher.Spec{
+		Executable: filepath.Join(config.AppDir, "bin", config.ExecutableName),
+		Args:       []string{"--defaults-file=" + config.ConfigFile, "--initialize"},
+	})
 	if err != nil {
 		return fmt.Errorf("MySQL initialization failed: %w\nOutput: %s", err, output)
 	}
@@ -234,17 +277,16 @@ func initializeMySQL(config *Configuration) error {
 	return nil
 }
 
-// updateMySQLConfig updates the MySQL configuration file
+// updateMySQLConfig renders the MySQL configuration file from the template
 func updateMySQLConfig(config *Configuration) error {
-	// Replace placeholders in the configuration file
-	replacements := map[string]string{
-		"{mysql_data_folder}": config.DataFolder,
+	data := templates.NewData(config.RootDir)
+	data.MySQL = templates.MySQL{
+		DataFolder: config.DataFolder,
+		Port:       config.Port,
 	}
 
-	for placeholder, value := range replacements {
-		if err := helpers.ReplaceInFile(config.ConfigFile, placeholder, value); err != nil {
-			return fmt.Errorf("failed to update MySQL configuration file: %w", err)
-		}
+	if err := templates.RenderToFile(config.ConfigTemplateFile, config.ConfigFile, data); err != nil {
+		return fmt.Errorf("failed to render MySQL configuration file: %w", err)
 	}
 
 	return nil
@@ -254,46 +296,60 @@ func updateMySQLConfig(config *Configuration) error {
 func startMySQLServer(config *Configuration) error {
 	log.Println("Starting MySQL server...")
 
-	mysqldPath := filepath.Join(config.AppDir, "bin", config.ExecutableName)
-	command := fmt.Sprintf("%s --defaults-file=%s", mysqldPath, config.ConfigFile)
-
-	if err := helpers.RunCommand(command, true); err != nil {
+	cmd, err := launcher.StartBackground(mysqldCommand(config), 500*time.Millisecond)
+	if err != nil {
 		return fmt.Errorf("failed to start MySQL server: %w", err)
 	}
 
+	if err := helpers.WritePIDFile(config.PIDFile, cmd.Process.Pid); err != nil {
+		return fmt.Errorf("failed to record MySQL PID: %w", err)
+	}
+
 	return nil
 }
 
-// verifyMySQLRunning verifies that MySQL is running
-func verifyMySQLRunning(config *Configuration) error {
-	// Wait for MySQL to start
-	maxRetries := 10
-	retryInterval := 500 * time.Millisecond
+// mysqldCommand returns the process running mysqld in the foreground
+func mysqldCommand(config *Configuration) launcher.Spec {
+	return logs.Capture(launcher.Spec{
+		Executable: filepath.Join(config.AppDir, "bin", config.ExecutableName),
+		// --defaults-file has to come first
+		Args: []string{"--defaults-file=" + config.ConfigFile, "--log-error=" + config.ErrorLog},
+	}, "mysql")
+}
 
-	log.Println("Verifying MySQL is running...")
+// LogFiles returns the MySQL error log
+func LogFiles() []string {
+	config, err := NewConfiguration()
+	if err != nil {
+		return nil
+	}
 
-	for i := 0; i < maxRetries; i++ {
-		running, pid := helpers.IsProcessRunning(config.ExecutableName)
-		if running {
-			log.Printf("MySQL is running with PID %d", pid)
-			return nil
-		}
+	return []string{config.ErrorLog}
+}
+
+// verifyMySQLRunning waits until the started mysqld accepts TCP connections
+func verifyMySQLRunning(config *Configuration) error {
+	log.Println("Verifying MySQL is running...")
 
-		log.Printf("Waiting for MySQL to start (attempt %d/%d)...", i+1, maxRetries)
-		time.Sleep(retryInterval)
+	if err := health.WaitFor(readinessProbe(config), config.ReadyTimeout); err != nil {
+		return fmt.Errorf("MySQL failed to start within the expected time: %w", err)
 	}
 
-	return fmt.Errorf("MySQL failed to start within the expected time")
+	log.Printf("MySQL is accepting connections on port %d", config.Port)
+	return nil
 }
 
-// gracefulShutdown attempts to shut down MySQL gracefully
-func gracefulShutdown(config *Configuration) error {
-	// This is a placeholder for a more graceful shutdown
-	// In a real implementation, you might use mysqladmin shutdown
-	// or another method to gracefully stop MySQL
+// readinessProbe checks that the recorded mysqld is alive and accepts TCP connections
+func readinessProbe(config *Configuration) health.Probe {
+	address := fmt.Sprintf("127.0.0.1:%d", config.Port)
+	return health.All(health.PIDFile(config.PIDFile), health.TCP(address))
+}
 
-	// For now, we'll just use the kill process method
-	return helpers.KillProcess(config.ExecutableName)
+// gracefulShutdown asks the recorded mysqld process to shut down and kills it after a timeout
+func gracefulShutdown(config *Configuration) error {
+	// mysqld flushes and closes tables on SIGTERM, which can take a while. Windows has no SIGTERM,
+	// there the process is killed with taskkill /F and InnoDB recovers on the next start
+	return helpers.StopPIDFile(config.PIDFile, 30*time.Second)
 }
 
 // extractTempPassword extracts the temporary root password from MySQL initialization output
@@ -0,0 +1,54 @@
+package mysql
+
+import (
+	"github.com/alexivashchenko/go-dev-server/launcher"
+	"github.com/alexivashchenko/go-dev-server/status"
+)
+
+// Service exposes the MySQL package to the service registry
+type Service struct{}
+
+// Name returns the service name used on the command line and in .env
+func (Service) Name() string {
+	return "MySQL"
+}
+
+// DependsOn returns the services that must be started before MySQL
+func (Service) DependsOn() []string {
+	return nil
+}
+
+// Start starts the MySQL service
+func (Service) Start() error {
+	return Start()
+}
+
+// Stop stops the MySQL service
+func (Service) Stop() error {
+	return Stop()
+}
+
+// Restart restarts the MySQL service
+func (Service) Restart() error {
+	return Restart()
+}
+
+// Status returns the current status of the MySQL service
+func (Service) Status() status.Status {
+	return GetStatus()
+}
+
+// Process returns the foreground process used by run mode
+func (Service) Process() (launcher.Spec, error) {
+	return Process()
+}
+
+// Ready waits until MySQL accepts requests
+func (Service) Ready() error {
+	return Ready()
+}
+
+// LogFiles returns the log files written by MySQL itself
+func (Service) LogFiles() []string {
+	return LogFiles()
+}
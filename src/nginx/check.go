@@ -0,0 +1,156 @@
+package nginx
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/alexivashchenko/go-dev-server/helpers"
+)
+
+// testErrorPattern matches a problem reported by nginx -t, e.g.
+// nginx: [emerg] unknown directive "foo" in C:\server/etc/nginx/sites-enabled/site.conf:12
+var testErrorPattern = regexp.MustCompile(`^nginx: \[(emerg|alert|crit|error)\] (.*)$`)
+
+// locationPattern matches the absolute file and line following " in " at the end of a problem
+var locationPattern = regexp.MustCompile(`^((?:[A-Za-z]:)?[/\\].*):(\d+)$`)
+
+// ConfigError is a configuration problem reported by nginx -t
+type ConfigError struct {
+	File    string
+	Line    int
+	Message string
+}
+
+// Error returns the problem prefixed with its location
+func (e *ConfigError) Error() string {
+	if e.File == "" {
+		return e.Message
+	}
+	return fmt.Sprintf("%s:%d: %s", e.File, e.Line, e.Message)
+}
+
+// Snippet returns the lines around the offending line of the generated file, the offending line marked with >
+func (e *ConfigError) Snippet(context int) string {
+	if e.File == "" || e.Line < 1 {
+		return ""
+	}
+
+	lines, err := helpers.ReadLinesIntoSlice(e.File)
+	if err != nil || e.Line > len(lines) {
+		return ""
+	}
+
+	var snippet strings.Builder
+	for number := max(1, e.Line-context); number <= min(len(lines), e.Line+context); number++ {
+		marker := " "
+		if number == e.Line {
+			marker = ">"
+		}
+		fmt.Fprintf(&snippet, "%s %4d | %s\n", marker, number, lines[number-1])
+	}
+
+	return snippet.String()
+}
+
+// parseTestOutput returns the first problem in the output of nginx -t
+func parseTestOutput(output string) *ConfigError {
+	for _, line := range strings.Split(output, "\n") {
+		match := testErrorPattern.FindStringSubmatch(strings.TrimSpace(line))
+		if match == nil {
+			continue
+		}
+
+		return splitLocation(match[2])
+	}
+
+	return nil
+}
+
+// splitLocation separates the file and line from a problem, messages such as
+// "host not found in upstream" contain " in " themselves, the location is the first absolute path
+func splitLocation(problem string) *ConfigError {
+	for offset := 0; ; {
+		index := strings.Index(problem[offset:], " in ")
+		if index < 0 {
+			return &ConfigError{Message: problem}
+		}
+		index += offset
+
+		if location := locationPattern.FindStringSubmatch(problem[index+len(" in "):]); location != nil {
+			line, _ := strconv.Atoi(location[2])
+			return &ConfigError{File: filepath.Clean(location[1]), Line: line, Message: problem[:index]}
+		}
+		offset = index + len(" in ")
+	}
+}
+
+// configBackup holds the content of the generated configuration files by path
+type configBackup map[string][]byte
+
+// backupConfiguration reads nginx.conf and the site configurations currently on disk
+func backupConfiguration(config *Configuration) configBackup {
+	backup := configBackup{}
+
+	files, _ := filepath.Glob(filepath.Join(config.SitesEnabledFolder, "*.conf"))
+	for _, file := range append(files, config.NginxConfFile) {
+		if content, err := os.ReadFile(file); err == nil {
+			backup[file] = content
+		}
+	}
+
+	return backup
+}
+
+// restore puts the backed up files back and removes site configurations created since
+func (b configBackup) restore(config *Configuration) error {
+	files, _ := filepath.Glob(filepath.Join(config.SitesEnabledFolder, "*.conf"))
+	for _, file := range files {
+		if _, ok := b[file]; !ok {
+			if err := os.Remove(file); err != nil {
+				return fmt.Errorf("failed to remove %s: %w", file, err)
+			}
+		}
+	}
+
+	for file, content := range b {
+		if err := helpers.WriteFileAtomic(file, content); err != nil {
+			return fmt.Errorf("failed to restore %s: %w", file, err)
+		}
+	}
+
+	return nil
+}
+
+// writeConfiguration renders and tests the configuration, the previous files are put back when the test fails
+func writeConfiguration(config *Configuration) error {
+	backup := backupConfiguration(config)
+
+	err := configureNginx(config)
+	if err != nil {
+		err = fmt.Errorf("failed to configure nginx: %w", err)
+	} else if err = createSiteConfigurations(config); err != nil {
+		err = fmt.Errorf("failed to create site configurations: %w", err)
+	} else if err = checkNginxConfiguration(config); err != nil {
+		err = fmt.Errorf("nginx configuration check failed: %w", err)
+	}
+	if err == nil {
+		return nil
+	}
+
+	// Without a previous configuration the broken files are left in place for inspection
+	if len(backup) == 0 {
+		return err
+	}
+	if restoreErr := backup.restore(config); restoreErr != nil {
+		log.Printf("Warning: Failed to restore the previous Nginx configuration: %v", restoreErr)
+		return err
+	}
+	log.Println("Restored the previous Nginx configuration")
+
+	return err
+}
@@ -0,0 +1,126 @@
+package nginx
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseTestOutput(t *testing.T) {
+	tests := []struct {
+		name   string
+		output string
+		want   *ConfigError
+	}{
+		{
+			name:   "error with location",
+			output: "nginx: [emerg] unknown directive \"foo\" in /srv/etc/nginx/sites-enabled/site.conf:12\nnginx: configuration file /srv/etc/nginx/nginx.conf test failed",
+			want:   &ConfigError{File: filepath.Clean("/srv/etc/nginx/sites-enabled/site.conf"), Line: 12, Message: "unknown directive \"foo\""},
+		},
+		{
+			name:   "error without line",
+			output: "nginx: [emerg] no \"events\" section in configuration\nnginx: configuration file /srv/etc/nginx/nginx.conf test failed",
+			want:   &ConfigError{Message: "no \"events\" section in configuration"},
+		},
+		{
+			name:   "warnings are skipped",
+			output: "nginx: [warn] conflicting server name \"a.test\" on 0.0.0.0:80, ignored\r\nnginx: [emerg] host not found in upstream \"php_upstream\" in /srv/site.conf:20\r\n",
+			want:   &ConfigError{File: filepath.Clean("/srv/site.conf"), Line: 20, Message: "host not found in upstream \"php_upstream\""},
+		},
+		{
+			name:   "windows path",
+			output: `nginx: [emerg] unexpected "}" in C:\server/etc/nginx/sites-enabled/site.conf:7`,
+			want:   &ConfigError{File: filepath.Clean(`C:\server/etc/nginx/sites-enabled/site.conf`), Line: 7, Message: `unexpected "}"`},
+		},
+		{
+			name:   "path containing in",
+			output: `nginx: [emerg] unknown directive "foo" in C:\Work in progress\server/etc/nginx/nginx.conf:3`,
+			want:   &ConfigError{File: filepath.Clean(`C:\Work in progress\server/etc/nginx/nginx.conf`), Line: 3, Message: `unknown directive "foo"`},
+		},
+		{
+			name:   "successful test",
+			output: "nginx: the configuration file /srv/etc/nginx/nginx.conf syntax is ok\nnginx: configuration file /srv/etc/nginx/nginx.conf test is successful",
+			want:   nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := parseTestOutput(tt.output)
+			if (got == nil) != (tt.want == nil) {
+				t.Fatalf("parseTestOutput() = %v, want %v", got, tt.want)
+			}
+			if got != nil && *got != *tt.want {
+				t.Errorf("parseTestOutput() = %+v, want %+v", *got, *tt.want)
+			}
+		})
+	}
+}
+
+func TestConfigErrorSnippet(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "site.conf")
+	if err := os.WriteFile(file, []byte("one\ntwo\nthree\nfour\nfive\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		err  ConfigError
+		want string
+	}{
+		{
+			name: "middle line",
+			err:  ConfigError{File: file, Line: 3},
+			want: "     2 | two\n>    3 | three\n     4 | four\n",
+		},
+		{
+			name: "first line",
+			err:  ConfigError{File: file, Line: 1},
+			want: ">    1 | one\n     2 | two\n",
+		},
+		{
+			name: "last line",
+			err:  ConfigError{File: file, Line: 5},
+			want: "     4 | four\n>    5 | five\n",
+		},
+		{
+			name: "without location",
+			err:  ConfigError{Message: "no \"events\" section in configuration"},
+			want: "",
+		},
+		{
+			name: "line beyond the file",
+			err:  ConfigError{File: file, Line: 9},
+			want: "",
+		},
+		{
+			name: "missing file",
+			err:  ConfigError{File: filepath.Join(t.TempDir(), "missing.conf"), Line: 1},
+			want: "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.err.Snippet(1); got != tt.want {
+				t.Errorf("Snippet() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestConfigErrorError(t *testing.T) {
+	tests := []struct {
+		err  ConfigError
+		want string
+	}{
+		{err: ConfigError{File: "site.conf", Line: 12, Message: "unknown directive"}, want: "site.conf:12: unknown directive"},
+		{err: ConfigError{Message: "no events section"}, want: "no events section"},
+	}
+
+	for _, tt := range tests {
+		if got := tt.err.Error(); got != tt.want {
+			t.Errorf("Error() = %q, want %q", got, tt.want)
+		}
+	}
+}
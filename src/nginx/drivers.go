@@ -0,0 +1,117 @@
+package nginx
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/alexivashchenko/go-dev-server/sites"
+)
+
+// Driver describes a kind of application, the site template writes its location blocks by name
+type Driver struct {
+	Name string
+	// DocRoot is the document root relative to the site folder
+	DocRoot string
+	// Detect reports whether a site folder holds such an application
+	Detect func(dir string) bool
+}
+
+// GenericDriver serves any folder with PHP and directory listings
+const GenericDriver = "generic"
+
+// ProxyDriver forwards requests of sites with PROXY set to an app server
+const ProxyDriver = "proxy"
+
+// drivers are checked in order, the first one detecting the site wins
+var drivers = []Driver{
+	{Name: "laravel", DocRoot: "public", Detect: hasFiles("artisan", "public/index.php")},
+	{Name: "symfony", DocRoot: "public", Detect: hasFiles("bin/console", "public/index.php")},
+	{Name: "craft", DocRoot: "web", Detect: hasFiles("craft", "web/index.php")},
+	{Name: "drupal", DocRoot: "web", Detect: hasFiles("web/core/lib/Drupal.php")},
+	{Name: "drupal", Detect: hasFiles("core/lib/Drupal.php")},
+	{Name: "wordpress-multisite", Detect: isWordPressMultisite},
+	{Name: "wordpress", Detect: hasFiles("wp-config.php")},
+	{Name: "spa", Detect: isStaticApp},
+	{Name: GenericDriver, Detect: func(string) bool { return true }},
+}
+
+// hasFiles returns a detector passing when all files exist in the site folder
+func hasFiles(files ...string) func(string) bool {
+	return func(dir string) bool {
+		for _, file := range files {
+			if _, err := os.Stat(filepath.Join(dir, filepath.FromSlash(file))); err != nil {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// isWordPressMultisite detects a WordPress installation with MULTISITE enabled in wp-config.php
+func isWordPressMultisite(dir string) bool {
+	content, err := os.ReadFile(filepath.Join(dir, "wp-config.php"))
+	if err != nil {
+		return false
+	}
+
+	config := strings.ReplaceAll(string(content), " ", "")
+	return strings.Contains(config, "'MULTISITE',true") || strings.Contains(config, "\"MULTISITE\",true")
+}
+
+// isStaticApp detects a single page application: an index.html and no PHP files next to it
+func isStaticApp(dir string) bool {
+	if !hasFiles("index.html")(dir) {
+		return false
+	}
+
+	phpFiles, _ := filepath.Glob(filepath.Join(dir, "*.php"))
+	return len(phpFiles) == 0
+}
+
+// DriverNames returns the names usable as DRIVER in a site configuration file
+func DriverNames() []string {
+	var names []string
+	seen := make(map[string]bool)
+	for _, driver := range drivers {
+		if !seen[driver.Name] {
+			seen[driver.Name] = true
+			names = append(names, driver.Name)
+		}
+	}
+
+	return names
+}
+
+// resolveDriver returns the driver configured for a site, or the detected one
+func resolveDriver(site sites.Site) (Driver, error) {
+	name := strings.ToLower(site.Driver)
+	if name == "" || name == "auto" {
+		for _, driver := range drivers {
+			if driver.Detect(site.Dir) {
+				return driver, nil
+			}
+		}
+	}
+
+	// A driver can have several layouts, prefer the one matching the folder
+	var candidates []Driver
+	for _, driver := range drivers {
+		if driver.Name == name {
+			candidates = append(candidates, driver)
+		}
+	}
+	if len(candidates) == 0 {
+		return Driver{}, fmt.Errorf("unknown driver %s for %s (available: auto, %s)",
+			site.Driver, site.Domain, strings.Join(DriverNames(), ", "))
+	}
+
+	for _, driver := range candidates {
+		if driver.Detect(site.Dir) {
+			return driver, nil
+		}
+	}
+
+	return candidates[0], nil
+}
@@ -0,0 +1,132 @@
+package nginx
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/alexivashchenko/go-dev-server/sites"
+)
+
+func TestResolveDriver(t *testing.T) {
+	tests := []struct {
+		name        string
+		files       map[string]string
+		driver      string
+		want        string
+		wantDocRoot string
+		wantErr     bool
+	}{
+		{
+			name:        "laravel",
+			files:       map[string]string{"artisan": "", "public/index.php": ""},
+			want:        "laravel",
+			wantDocRoot: "public",
+		},
+		{
+			name:        "symfony",
+			files:       map[string]string{"bin/console": "", "public/index.php": ""},
+			want:        "symfony",
+			wantDocRoot: "public",
+		},
+		{
+			name:        "craft",
+			files:       map[string]string{"craft": "", "web/index.php": ""},
+			want:        "craft",
+			wantDocRoot: "web",
+		},
+		{
+			name:        "drupal with web folder",
+			files:       map[string]string{"web/core/lib/Drupal.php": ""},
+			want:        "drupal",
+			wantDocRoot: "web",
+		},
+		{
+			name:  "drupal in the site folder",
+			files: map[string]string{"core/lib/Drupal.php": ""},
+			want:  "drupal",
+		},
+		{
+			name:  "wordpress",
+			files: map[string]string{"wp-config.php": "<?php\ndefine('DB_NAME', 'wp');\n"},
+			want:  "wordpress",
+		},
+		{
+			name:  "wordpress multisite",
+			files: map[string]string{"wp-config.php": "<?php\ndefine( 'MULTISITE', true );\n"},
+			want:  "wordpress-multisite",
+		},
+		{
+			name:  "wordpress multisite disabled",
+			files: map[string]string{"wp-config.php": "<?php\ndefine('MULTISITE', false);\n"},
+			want:  "wordpress",
+		},
+		{
+			name:  "single page application",
+			files: map[string]string{"index.html": "", "assets/app.js": ""},
+			want:  "spa",
+		},
+		{
+			name:  "index.html next to PHP files",
+			files: map[string]string{"index.html": "", "contact.php": ""},
+			want:  GenericDriver,
+		},
+		{
+			name:  "generic fallback",
+			files: map[string]string{"info.php": ""},
+			want:  GenericDriver,
+		},
+		{
+			name:        "auto detects",
+			files:       map[string]string{"artisan": "", "public/index.php": ""},
+			driver:      "auto",
+			want:        "laravel",
+			wantDocRoot: "public",
+		},
+		{
+			name:        "explicit driver",
+			files:       map[string]string{"artisan": "", "public/index.php": ""},
+			driver:      "Symfony",
+			want:        "symfony",
+			wantDocRoot: "public",
+		},
+		{
+			name:   "explicit driver picks the layout found",
+			files:  map[string]string{"core/lib/Drupal.php": ""},
+			driver: "drupal",
+			want:   "drupal",
+		},
+		{
+			name:    "unknown driver",
+			files:   map[string]string{"index.php": ""},
+			driver:  "rails",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			for name, content := range tt.files {
+				path := filepath.Join(dir, filepath.FromSlash(name))
+				if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			got, err := resolveDriver(sites.Site{Dir: dir, Domain: "shop.test", Driver: tt.driver})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("resolveDriver() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if got.Name != tt.want || got.DocRoot != tt.wantDocRoot {
+				t.Errorf("resolveDriver() = %s with docroot %q, want %s with docroot %q", got.Name, got.DocRoot, tt.want, tt.wantDocRoot)
+			}
+		})
+	}
+}
@@ -6,10 +6,18 @@ import (
 	"os"
 	"path/filepath"
 	"runtime"
+	"slices"
 	"strings"
 	"time"
 
+	"github.com/alexivashchenko/go-dev-server/health"
 	"github.com/alexivashchenko/go-dev-server/helpers"
+	"github.com/alexivashchenko/go-dev-server/launcher"
+	"github.com/alexivashchenko/go-dev-server/logs"
+	"github.com/alexivashchenko/go-dev-server/php"
+	"github.com/alexivashchenko/go-dev-server/sites"
+	"github.com/alexivashchenko/go-dev-server/status"
+	"github.com/alexivashchenko/go-dev-server/templates"
 )
 
 // Configuration holds all Nginx-related settings
@@ -17,6 +25,7 @@ type Configuration struct {
 	RootDir             string
 	AppFolder           string
 	AppPath             string
+	NginxConfFile       string
 	DomainTail          string
 	EtcFolder           string
 	SitesEnabledFolder  string
@@ -27,6 +36,8 @@ type Configuration struct {
 	TemplatesDir        string
 	LogsDir             string
 	ExecutableName      string
+	PIDFile             string
+	ReadyTimeout        time.Duration
 	DefaultConfTemplate string
 	NginxConfTemplate   string
 	GeneralSiteTemplate string
@@ -67,16 +78,19 @@ func NewConfiguration() (*Configuration, error) {
 		HostsFilePath:       hostsFilePath,
 		HostsFileIdentifier: "#local server setting",
 		ExecutableName:      executableName,
+		ReadyTimeout:        helpers.GetEnvDuration("NGINX_READY_TIMEOUT", 10*time.Second),
 	}
 
 	// Set paths
 	config.AppPath = filepath.Join(rootDir, "apps", "nginx", nginxAppFolder)
+	config.NginxConfFile = filepath.Join(config.AppPath, "conf", "nginx.conf")
 	config.EtcFolder = filepath.Join(rootDir, "etc", "nginx")
 	config.SitesEnabledFolder = filepath.Join(config.EtcFolder, "sites-enabled")
 	config.TmpHostsFilePath = filepath.Join(rootDir, "hosts.tmp")
 	config.WWWDir = filepath.Join(rootDir, "www")
 	config.TemplatesDir = filepath.Join(rootDir, "tpl")
 	config.LogsDir = filepath.Join(rootDir, "logs", "nginx")
+	config.PIDFile = helpers.GetPIDFilePath("nginx")
 
 	// Template files
 	config.DefaultConfTemplate = filepath.Join(config.TemplatesDir, "nginx", "00-default.conf.tpl")
@@ -110,44 +124,53 @@ func Start() error {
 		return fmt.Errorf("failed to initialize nginx configuration: %w", err)
 	}
 
-	// Create required directories
-	if err := ensureDirectoriesExist(config); err != nil {
-		return fmt.Errorf("failed to create required directories: %w", err)
+	if pids, _ := helpers.GetRunningPIDs(config.PIDFile); len(pids) > 0 {
+		log.Printf("Nginx is already running (PID: %d)", pids[0])
+		return nil
 	}
 
-	// Update hosts file
-	if err := updateHostsFile(config); err != nil {
-		return fmt.Errorf("failed to update hosts file: %w", err)
+	// Generate configuration and hosts entries
+	if err := prepareNginx(config); err != nil {
+		return err
 	}
 
-	// Configure Nginx
-	if err := configureNginx(config); err != nil {
-		return fmt.Errorf("failed to configure nginx: %w", err)
+	// Start Nginx
+	if err := startNginx(config); err != nil {
+		return fmt.Errorf("failed to start nginx: %w", err)
 	}
 
-	// Create site configurations
-	if err := createSiteConfigurations(config); err != nil {
-		return fmt.Errorf("failed to create site configurations: %w", err)
+	// Wait until the default server answers
+	if err := verifyNginxRunning(config); err != nil {
+		return err
 	}
 
-	// Create log files
-	if err := createLogFiles(config); err != nil {
-		return fmt.Errorf("failed to create log files: %w", err)
+	elapsed := time.Since(startTime)
+	log.Printf("Nginx service started successfully in %.2f seconds", elapsed.Seconds())
+	return nil
+}
+
+// Process prepares Nginx and returns the nginx command to run in the foreground
+func Process() (launcher.Spec, error) {
+	config, err := NewConfiguration()
+	if err != nil {
+		return launcher.Spec{}, fmt.Errorf("failed to initialize nginx configuration: %w", err)
 	}
 
-	// Check Nginx configuration
-	if err := checkNginxConfiguration(config); err != nil {
-		return fmt.Errorf("nginx configuration check failed: %w", err)
+	if err := prepareNginx(config); err != nil {
+		return launcher.Spec{}, err
 	}
 
-	// Start Nginx
-	if err := startNginx(config); err != nil {
-		return fmt.Errorf("failed to start nginx: %w", err)
+	return nginxCommand(config), nil
+}
+
+// Ready waits until the Nginx default server answers HTTP requests
+func Ready() error {
+	config, err := NewConfiguration()
+	if err != nil {
+		return fmt.Errorf("failed to initialize nginx configuration: %w", err)
 	}
 
-	elapsed := time.Since(startTime)
-	log.Printf("Nginx service started successfully in %.2f seconds", elapsed.Seconds())
-	return nil
+	return verifyNginxRunning(config)
 }
 
 // Stop stops the Nginx service
@@ -160,8 +183,8 @@ func Stop() error {
 		return fmt.Errorf("failed to initialize nginx configuration: %w", err)
 	}
 
-	// Kill Nginx process
-	if err := helpers.KillProcess(config.ExecutableName); err != nil {
+	// Stop the recorded Nginx master process, it takes its workers down with it
+	if err := helpers.StopPIDFile(config.PIDFile, 10*time.Second); err != nil {
 		return fmt.Errorf("failed to stop nginx process: %w", err)
 	}
 
@@ -189,18 +212,81 @@ func Restart() error {
 	return nil
 }
 
+// Reload regenerates the configuration and gracefully reloads the running Nginx
+func Reload() error {
+	log.Println("Reloading Nginx service...")
+	startTime := time.Now()
+
+	config, err := NewConfiguration()
+	if err != nil {
+		return fmt.Errorf("failed to initialize nginx configuration: %w", err)
+	}
+
+	if pids, _ := helpers.GetRunningPIDs(config.PIDFile); len(pids) == 0 {
+		return fmt.Errorf("nginx is not running, start it with \"server start nginx\"")
+	}
+
+	if err := ensureDirectoriesExist(config); err != nil {
+		return fmt.Errorf("failed to create required directories: %w", err)
+	}
+
+	// Never signal the running master with a configuration it would reject
+	if err := writeConfiguration(config); err != nil {
+		return err
+	}
+
+	// The new domains only point here once Nginx accepted their sites
+	if err := updateHostsFile(config); err != nil {
+		return fmt.Errorf("failed to update hosts file: %w", err)
+	}
+
+	if err := reloadNginx(config); err != nil {
+		return err
+	}
+
+	elapsed := time.Since(startTime)
+	log.Printf("Nginx service reloaded successfully in %.2f seconds", elapsed.Seconds())
+	return nil
+}
+
 // GetStatus returns the current status of the Nginx service
-func GetStatus() string {
+func GetStatus() status.Status {
 	config, err := NewConfiguration()
 	if err != nil {
-		return "Error: " + err.Error()
+		return status.FromError(err)
+	}
+
+	result := status.FromPIDFile(config.PIDFile)
+	result.Addresses = []string{"0.0.0.0:80", "0.0.0.0:443"}
+	result.Version = status.Version(filepath.Join(config.AppPath, config.ExecutableName), "-v")
+	result.CheckHealth(readinessProbe(config))
+
+	return result
+}
+
+// prepareNginx writes the log files, nginx.conf and site configurations, checks them and then writes the hosts file
+func prepareNginx(config *Configuration) error {
+	// Create required directories
+	if err := ensureDirectoriesExist(config); err != nil {
+		return fmt.Errorf("failed to create required directories: %w", err)
+	}
+
+	// Create log files
+	if err := createLogFiles(config); err != nil {
+		return fmt.Errorf("failed to create log files: %w", err)
+	}
+
+	// Render and check nginx.conf and the site configurations
+	if err := writeConfiguration(config); err != nil {
+		return err
 	}
 
-	running, pid := helpers.IsProcessRunning(config.ExecutableName)
-	if running {
-		return fmt.Sprintf("Running (PID: %d)", pid)
+	// Update hosts file, a rejected configuration doesn't prompt for admin rights
+	if err := updateHostsFile(config); err != nil {
+		return fmt.Errorf("failed to update hosts file: %w", err)
 	}
-	return "Stopped"
+
+	return nil
 }
 
 // ensureDirectoriesExist creates necessary directories
@@ -220,20 +306,8 @@ func ensureDirectoriesExist(config *Configuration) error {
 	return nil
 }
 
-// updateHostsFile updates the hosts file with site entries
+// updateHostsFile updates the hosts file with site entries, an up-to-date file is left untouched
 func updateHostsFile(config *Configuration) error {
-	log.Println("Updating hosts file...")
-
-	// Remove temporary hosts file if it exists
-	if err := helpers.RemoveFile(config.TmpHostsFilePath); err != nil && !os.IsNotExist(err) {
-		return fmt.Errorf("failed to remove temporary hosts file: %w", err)
-	}
-
-	// Create new temporary hosts file
-	if err := helpers.CreateFile(config.TmpHostsFilePath); err != nil {
-		return fmt.Errorf("failed to create temporary hosts file: %w", err)
-	}
-
 	// Read current hosts file
 	lines, err := helpers.ReadLinesIntoSlice(config.HostsFilePath)
 	if err != nil {
@@ -248,21 +322,43 @@ func updateHostsFile(config *Configuration) error {
 		}
 	}
 
-	// Get website directories
-	dirs, err := helpers.ListDirectories(config.WWWDir)
+	siteList, err := sites.All()
 	if err != nil {
-		return fmt.Errorf("failed to list website directories: %w", err)
+		return err
 	}
 
-	// Add new entries for each website
-	for _, dir := range dirs {
-		baseName := filepath.Base(dir)
+	// Add new entries for each website, wildcard aliases can't go into the hosts file
+	for _, site := range siteList {
+		var domains []string
+		for _, domain := range site.Domains() {
+			if !strings.HasPrefix(domain, "*.") {
+				domains = append(domains, domain)
+			}
+		}
 		hostEntry := fmt.Sprintf("127.0.0.1\t%s\t%s",
-			baseName+"."+config.DomainTail,
+			strings.Join(domains, " "),
 			config.HostsFileIdentifier)
 		newLines = append(newLines, hostEntry)
 	}
 
+	// Writing the hosts file needs admin rights, don't ask for them when nothing changed
+	if slices.Equal(lines, newLines) {
+		log.Println("Hosts file is up to date")
+		return nil
+	}
+
+	log.Println("Updating hosts file...")
+
+	// Remove temporary hosts file if it exists
+	if err := helpers.RemoveFile(config.TmpHostsFilePath); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove temporary hosts file: %w", err)
+	}
+
+	// Create new temporary hosts file
+	if err := helpers.CreateFile(config.TmpHostsFilePath); err != nil {
+		return fmt.Errorf("failed to create temporary hosts file: %w", err)
+	}
+
 	// Write to temporary file
 	if err := helpers.AppendLines(config.TmpHostsFilePath, newLines); err != nil {
 		return fmt.Errorf("failed to write to temporary hosts file: %w", err)
@@ -276,21 +372,18 @@ func updateHostsFile(config *Configuration) error {
 	return nil
 }
 
-// configureNginx configures the Nginx server
+// configureNginx renders nginx.conf and the default site
 func configureNginx(config *Configuration) error {
 	log.Println("Configuring Nginx...")
 
-	// Copy and configure main nginx.conf
-	nginxConfFile := filepath.Join(config.AppPath, "conf", "nginx.conf")
-
-	if err := helpers.CopyFile(config.NginxConfTemplate, nginxConfFile); err != nil {
-		return fmt.Errorf("failed to copy nginx configuration template: %w", err)
+	data, err := newTemplateData(config)
+	if err != nil {
+		return err
 	}
 
-	// Replace placeholders in nginx.conf
-	rootDirFormatted := helpers.ReplaceBackslashToSlash(config.RootDir + string(os.PathSeparator))
-	if err := helpers.ReplaceInFile(nginxConfFile, "{root_folder}", rootDirFormatted); err != nil {
-		return fmt.Errorf("failed to update nginx configuration: %w", err)
+	// Render main nginx.conf
+	if err := templates.RenderToFile(config.NginxConfTemplate, config.NginxConfFile, data); err != nil {
+		return fmt.Errorf("failed to render nginx configuration: %w", err)
 	}
 
 	// Clean and recreate sites-enabled directory
@@ -302,96 +395,144 @@ func configureNginx(config *Configuration) error {
 		return fmt.Errorf("failed to create sites-enabled directory: %w", err)
 	}
 
-	// Copy and configure default site
+	// Render default site
 	defaultConfFile := filepath.Join(config.SitesEnabledFolder, "00-default.conf")
+	if err := templates.RenderToFile(config.DefaultConfTemplate, defaultConfFile, data); err != nil {
+		return fmt.Errorf("failed to render default site configuration: %w", err)
+	}
+
+	return nil
+}
 
-	if err := helpers.CopyFile(config.DefaultConfTemplate, defaultConfFile); err != nil {
-		return fmt.Errorf("failed to copy default site template: %w", err)
+// createSiteConfigurations renders a configuration file for each site
+func createSiteConfigurations(config *Configuration) error {
+	log.Println("Creating site configurations...")
+
+	data, err := newTemplateData(config)
+	if err != nil {
+		return err
 	}
 
-	if err := helpers.ReplaceInFile(defaultConfFile, "{root_folder}", rootDirFormatted); err != nil {
-		return fmt.Errorf("failed to update default site configuration: %w", err)
+	for _, site := range data.Sites {
+		if site.Proxy != "" {
+			log.Printf("%s: proxy to %s", site.Domain, site.Proxy)
+		} else {
+			log.Printf("%s: %s driver, serving %s", site.Domain, site.Driver, site.Root)
+		}
+		siteConfFile := filepath.Join(config.SitesEnabledFolder, site.Domain+".conf")
+
+		data.Site = site
+		if err := templates.RenderToFile(config.GeneralSiteTemplate, siteConfFile, data); err != nil {
+			return fmt.Errorf("failed to render site configuration for %s: %w", site.Domain, err)
+		}
 	}
 
 	return nil
 }
 
-// createSiteConfigurations creates configuration files for each site
-func createSiteConfigurations(config *Configuration) error {
-	log.Println("Creating site configurations...")
+// newTemplateData returns the template data with all sites and PHP upstreams
+func newTemplateData(config *Configuration) (templates.Data, error) {
+	data := templates.NewData(config.RootDir)
 
-	// Get website directories
-	dirs, err := helpers.ListDirectories(config.WWWDir)
+	// Sites keep working on the default upstream when PHP isn't configured
+	pools, err := php.Configurations()
 	if err != nil {
-		return fmt.Errorf("failed to list website directories: %w", err)
+		log.Printf("Warning: %v, all sites use %s", err, php.UpstreamName)
 	}
+	data.Upstreams = php.Upstreams(pools)
 
-	// Create configuration for each site
-	for _, dir := range dirs {
-		baseName := filepath.Base(dir)
-		domainName := baseName + "." + config.DomainTail
-		siteConfFile := filepath.Join(config.SitesEnabledFolder, domainName+".conf")
+	siteList, err := sites.All()
+	if err != nil {
+		return data, err
+	}
 
-		// Copy site template
-		if err := helpers.CopyFile(config.GeneralSiteTemplate, siteConfFile); err != nil {
-			return fmt.Errorf("failed to copy site template for %s: %w", domainName, err)
+	for _, site := range siteList {
+		// Proxy sites forward every request, there is nothing to detect
+		driver := Driver{Name: ProxyDriver}
+		if site.Proxy == "" {
+			driver, err = resolveDriver(site)
+			if err != nil {
+				return data, err
+			}
+		}
+		// An explicit DOCROOT wins over the one of the driver
+		if site.DocRoot == "" {
+			site.DocRoot = driver.DocRoot
 		}
 
-		// Replace placeholders
-		replacements := map[string]string{
-			"{root_folder}": helpers.ReplaceBackslashToSlash(config.RootDir + string(os.PathSeparator)),
-			"{folder_name}": baseName,
-			"{domain_name}": domainName,
+		upstream := php.UpstreamFor(pools, site.PHPVersion)
+		if upstream == "" {
+			upstream = php.UpstreamName
 		}
 
-		for placeholder, value := range replacements {
-			if err := helpers.ReplaceInFile(siteConfFile, placeholder, value); err != nil {
-				return fmt.Errorf("failed to update site configuration for %s: %w", domainName, err)
-			}
+		root := ""
+		if site.Root() != "" {
+			root = strings.TrimSuffix(helpers.ReplaceBackslashToSlash(site.Root()), "/") + "/"
 		}
-	}
 
-	return nil
+		if site.HTTPSOnly && !data.SSL.Enabled {
+			log.Printf("Warning: %s is HTTPS only but no certificate has been generated yet, serving HTTP", site.Domain)
+		}
+
+		data.Sites = append(data.Sites, templates.Site{
+			Name:      site.Name,
+			Domain:    site.Domain,
+			Aliases:   site.Aliases,
+			Root:      root,
+			Driver:    driver.Name,
+			Proxy:     site.Proxy,
+			HTTPSOnly: site.HTTPSOnly,
+			Snippet:   helpers.ReplaceBackslashToSlash(site.Snippet),
+			Env:       site.Env,
+			Upstream:  upstream,
+		})
+	}
+
+	return data, nil
 }
 
-// createLogFiles creates log files for Nginx and each site
+// createLogFiles archives the logs of the previous run and creates log files for Nginx and each site
 func createLogFiles(config *Configuration) error {
 	log.Println("Creating log files...")
 
-	// Clean and recreate logs directory
-	if err := helpers.RemoveDirectoryAndContents(config.LogsDir); err != nil && !os.IsNotExist(err) {
-		log.Printf("Warning: Failed to clean logs directory: %v", err)
-	}
-
 	if err := os.MkdirAll(config.LogsDir, 0755); err != nil {
 		return fmt.Errorf("failed to create logs directory: %w", err)
 	}
 
+	// Keep the previous logs as archives, they are needed to debug a crash after a restart
+	for _, file := range LogFiles() {
+		if filepath.Base(file) == logs.OutputFileName {
+			continue
+		}
+		if err := logs.Archive(file); err != nil {
+			log.Printf("Warning: Failed to archive %s: %v", file, err)
+		}
+	}
+
 	// Create main error log
 	mainErrorLog := filepath.Join(config.LogsDir, "error.log")
-	if err := helpers.RemoveOldFileAndCreateNew(mainErrorLog); err != nil {
+	if err := helpers.CreateFile(mainErrorLog); err != nil {
 		return fmt.Errorf("failed to create main error log: %w", err)
 	}
 
 	// Create logs for each site
-	dirs, err := helpers.ListDirectories(config.WWWDir)
+	siteList, err := sites.All()
 	if err != nil {
-		return fmt.Errorf("failed to list website directories: %w", err)
+		return err
 	}
 
-	for _, dir := range dirs {
-		baseName := filepath.Base(dir)
-		domainName := baseName + "." + config.DomainTail
+	for _, site := range siteList {
+		domainName := site.Domain
 
 		// Create error log
 		siteErrorLog := filepath.Join(config.LogsDir, "error-"+domainName+".log")
-		if err := helpers.RemoveOldFileAndCreateNew(siteErrorLog); err != nil {
+		if err := helpers.CreateFile(siteErrorLog); err != nil {
 			return fmt.Errorf("failed to create error log for %s: %w", domainName, err)
 		}
 
 		// Create access log
 		siteAccessLog := filepath.Join(config.LogsDir, "access-"+domainName+".log")
-		if err := helpers.RemoveOldFileAndCreateNew(siteAccessLog); err != nil {
+		if err := helpers.CreateFile(siteAccessLog); err != nil {
 			return fmt.Errorf("failed to create access log for %s: %w", domainName, err)
 		}
 	}
@@ -399,39 +540,96 @@ func createLogFiles(config *Configuration) error {
 	return nil
 }
 
-// checkNginxConfiguration checks if the Nginx configuration is valid
+// checkNginxConfiguration runs nginx -t and returns the reported problem as a *ConfigError
 func checkNginxConfiguration(config *Configuration) error {
 	log.Println("Checking Nginx configuration...")
 
-	command := fmt.Sprintf("%s -p %s -t",
-		filepath.Join(config.AppPath, config.ExecutableName),
-		config.AppPath)
+	spec := launcher.Spec{
+		Executable: filepath.Join(config.AppPath, config.ExecutableName),
+		Args:       []string{"-p", config.AppPath, "-t"},
+	}
+
+	output, err := launcher.Output(spec)
+	if err == nil {
+		return nil
+	}
 
-	if err := helpers.RunCommand(command, true); err != nil {
-		return fmt.Errorf("nginx configuration test failed: %w", err)
+	configErr := parseTestOutput(output)
+	if configErr == nil {
+		return fmt.Errorf("%w: %s", err, strings.TrimSpace(output))
 	}
 
-	return nil
+	// Show the generated lines, the template line behind them is usually obvious from there
+	if snippet := configErr.Snippet(3); snippet != "" {
+		log.Printf("Nginx rejected %s:\n%s", configErr.File, snippet)
+	}
+
+	return configErr
 }
 
 // startNginx starts the Nginx server
 func startNginx(config *Configuration) error {
 	log.Println("Starting Nginx server...")
 
-	command := fmt.Sprintf("%s -p %s",
-		filepath.Join(config.AppPath, config.ExecutableName),
-		config.AppPath)
-
-	if err := helpers.RunCommand(command, true); err != nil {
+	// Nginx stays in the foreground so the recorded PID is the master process
+	cmd, err := launcher.StartBackground(nginxCommand(config), 500*time.Millisecond)
+	if err != nil {
 		return fmt.Errorf("failed to start nginx: %w", err)
 	}
 
-	// Verify process is running
-	time.Sleep(500 * time.Millisecond) // Give process time to start
-	running, _ := helpers.IsProcessRunning(config.ExecutableName)
-	if !running {
-		return fmt.Errorf("nginx process failed to start")
+	if err := helpers.WritePIDFile(config.PIDFile, cmd.Process.Pid); err != nil {
+		return fmt.Errorf("failed to record nginx PID: %w", err)
+	}
+
+	return nil
+}
+
+// reloadNginx tells the master process to start workers with the new configuration and retire the old ones
+func reloadNginx(config *Configuration) error {
+	log.Println("Reloading Nginx configuration...")
+
+	spec := launcher.Spec{
+		Executable: filepath.Join(config.AppPath, config.ExecutableName),
+		Args:       []string{"-p", config.AppPath, "-s", "reload"},
+	}
+
+	if err := launcher.Run(spec); err != nil {
+		return fmt.Errorf("failed to reload nginx: %w", err)
+	}
+
+	return nil
+}
+
+// verifyNginxRunning requests the default server until it answers
+func verifyNginxRunning(config *Configuration) error {
+	if err := health.WaitFor(readinessProbe(config), config.ReadyTimeout); err != nil {
+		return fmt.Errorf("nginx is not answering: %w", err)
 	}
 
 	return nil
 }
+
+// readinessProbe checks that the recorded nginx master is alive and the default server answers
+func readinessProbe(config *Configuration) health.Probe {
+	// favicon.ico is answered by nginx itself, so the check doesn't depend on PHP
+	return health.All(health.PIDFile(config.PIDFile), health.HTTP("http://127.0.0.1/favicon.ico"))
+}
+
+// nginxCommand returns the process running the Nginx master process in the foreground
+func nginxCommand(config *Configuration) launcher.Spec {
+	return logs.Capture(launcher.Spec{
+		Executable: filepath.Join(config.AppPath, config.ExecutableName),
+		Args:       []string{"-p", config.AppPath, "-g", "daemon off;"},
+	}, "nginx")
+}
+
+// LogFiles returns the main error log and the access and error logs of every site
+func LogFiles() []string {
+	config, err := NewConfiguration()
+	if err != nil {
+		return nil
+	}
+
+	files, _ := filepath.Glob(filepath.Join(config.LogsDir, "*.log"))
+	return files
+}
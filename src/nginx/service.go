@@ -0,0 +1,59 @@
+package nginx
+
+import (
+	"github.com/alexivashchenko/go-dev-server/launcher"
+	"github.com/alexivashchenko/go-dev-server/status"
+)
+
+// Service exposes the Nginx package to the service registry
+type Service struct{}
+
+// Name returns the service name used on the command line and in .env
+func (Service) Name() string {
+	return "Nginx"
+}
+
+// DependsOn returns the services that must be started before Nginx
+func (Service) DependsOn() []string {
+	return []string{"SSL", "PHP"}
+}
+
+// Start starts the Nginx service
+func (Service) Start() error {
+	return Start()
+}
+
+// Stop stops the Nginx service
+func (Service) Stop() error {
+	return Stop()
+}
+
+// Restart restarts the Nginx service
+func (Service) Restart() error {
+	return Restart()
+}
+
+// Reload applies configuration changes without stopping Nginx
+func (Service) Reload() error {
+	return Reload()
+}
+
+// Status returns the current status of the Nginx service
+func (Service) Status() status.Status {
+	return GetStatus()
+}
+
+// Process returns the foreground process used by run mode
+func (Service) Process() (launcher.Spec, error) {
+	return Process()
+}
+
+// Ready waits until Nginx accepts requests
+func (Service) Ready() error {
+	return Ready()
+}
+
+// LogFiles returns the log files written by Nginx itself
+func (Service) LogFiles() []string {
+	return LogFiles()
+}
@@ -0,0 +1,200 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/alexivashchenko/go-dev-server/helpers"
+	"github.com/alexivashchenko/go-dev-server/nginx"
+	"github.com/alexivashchenko/go-dev-server/php"
+)
+
+// phpCommand runs the php subcommands managing the installed PHP versions and extensions
+func phpCommand(args []string) {
+	subcommand := ""
+	if len(args) > 0 {
+		subcommand = args[0]
+	}
+
+	switch {
+	case subcommand == "list" && len(args) == 1:
+		listPHPVersions()
+	case subcommand == "current" && len(args) == 1:
+		showPHPVersion()
+	case subcommand == "use" && len(args) == 2:
+		usePHPVersion(args[1])
+	case subcommand == "ext" && len(args) == 2 && args[1] == "list":
+		listPHPExtensions()
+	case subcommand == "ext" && len(args) == 3 && (args[1] == "enable" || args[1] == "disable"):
+		setPHPExtension(args[2], args[1] == "enable")
+	default:
+		fmt.Println("Usage: server php list|current|use <version>")
+		fmt.Println("       server php ext list|enable <name>|disable <name>")
+		os.Exit(1)
+	}
+}
+
+// listPHPVersions lists the PHP versions in apps/php and marks the ones in use
+func listPHPVersions() {
+	installations, err := php.Installed()
+	if err != nil {
+		fmt.Printf("Error: %s\n", err)
+		os.Exit(1)
+	}
+
+	if len(installations) == 0 {
+		fmt.Println("No PHP versions found in apps/php")
+		return
+	}
+
+	inUse := make(map[string]string)
+	if configs, err := php.Configurations(); err == nil {
+		for i, config := range configs {
+			inUse[config.AppFolder] = "used by sites"
+			if i == 0 {
+				inUse[config.AppFolder] = "default"
+			}
+		}
+	}
+
+	writer := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(writer, "VERSION\tFOLDER\tSTATE")
+	for _, installation := range installations {
+		fmt.Fprintf(writer, "%s\t%s\t%s\n", installation.Version, installation.Folder, orDash(inUse[installation.Folder]))
+	}
+	writer.Flush()
+}
+
+// showPHPVersion prints the default PHP version and the other versions sites run on
+func showPHPVersion() {
+	configs, err := php.Configurations()
+	if err != nil {
+		fmt.Printf("Error: %s\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("PHP %s (%s)\n", orDash(configs[0].Version), configs[0].AppFolder)
+	for _, config := range configs[1:] {
+		fmt.Printf("PHP %s (%s) for sites asking for it\n", config.Version, config.AppFolder)
+	}
+}
+
+// usePHPVersion switches the default PHP version and restarts PHP when it is running
+func usePHPVersion(version string) {
+	installation, err := php.Use(version)
+	if err != nil {
+		fmt.Printf("Error: %s\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("Using PHP %s (%s)\n", installation.Version, installation.Folder)
+
+	if !restartRunningPHP() {
+		return
+	}
+
+	// Sites asking for the old or the new default version may now use another upstream
+	if pids, _ := helpers.GetRunningPIDs(helpers.GetPIDFilePath("nginx")); len(pids) > 0 {
+		if err := nginx.Reload(); err != nil {
+			fmt.Printf("Error: %s\n", err)
+			os.Exit(1)
+		}
+	}
+}
+
+// listPHPExtensions lists the extensions in the extension directory of the default PHP version
+func listPHPExtensions() {
+	config, err := php.NewConfiguration()
+	if err != nil {
+		fmt.Printf("Error: %s\n", err)
+		os.Exit(1)
+	}
+
+	extensions, err := php.Extensions(config)
+	if err != nil {
+		fmt.Printf("Error: %s\n", err)
+		os.Exit(1)
+	}
+
+	writer := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(writer, "EXTENSION\tSTATE\tFILE")
+	found := make(map[string]bool)
+	for _, extension := range extensions {
+		state := "-"
+		if extension.Enabled {
+			state = "enabled"
+		}
+		fmt.Fprintf(writer, "%s\t%s\t%s\n", extension.Name, state, extension.File)
+		found[extension.Name] = true
+	}
+	for _, name := range php.EnabledExtensions() {
+		if !found[name] {
+			fmt.Fprintf(writer, "%s\t%s\t%s\n", name, "missing", "-")
+		}
+	}
+	writer.Flush()
+}
+
+// setPHPExtension enables or disables an extension and restarts PHP when it is running
+func setPHPExtension(name string, enable bool) {
+	if err := php.SetExtension(name, enable); err != nil {
+		fmt.Printf("Error: %s\n", err)
+		os.Exit(1)
+	}
+
+	if enable {
+		fmt.Printf("Enabled extension %s\n", name)
+	} else {
+		fmt.Printf("Disabled extension %s\n", name)
+	}
+
+	restartRunningPHP()
+}
+
+// setXdebug turns Xdebug on or off and restarts PHP when it is running
+func setXdebug(args []string, flags map[string]string) {
+	if len(args) != 1 || (args[0] != "on" && args[0] != "off") {
+		fmt.Println("Usage: server xdebug on|off [--mode=debug,coverage]")
+		os.Exit(1)
+	}
+
+	if err := php.SetXdebug(args[0] == "on", flags["mode"]); err != nil {
+		fmt.Printf("Error: %s\n", err)
+		os.Exit(1)
+	}
+
+	config, err := php.NewConfiguration()
+	if err != nil {
+		fmt.Printf("Error: %s\n", err)
+		os.Exit(1)
+	}
+
+	if args[0] == "on" {
+		fmt.Printf("Xdebug is on (mode %s), connecting to %s:%d\n", config.Xdebug.Mode, config.Xdebug.ClientHost, config.Xdebug.ClientPort)
+	} else {
+		fmt.Println("Xdebug is off")
+	}
+
+	restartRunningPHP()
+}
+
+// restartRunningPHP restarts PHP to apply a changed php.ini or .env, it reports whether PHP was restarted
+func restartRunningPHP() bool {
+	if pids, _ := helpers.GetRunningPIDs(helpers.GetPIDFilePath("php")); len(pids) == 0 {
+		return false
+	}
+
+	// The supervisor keeps the environment it was started with
+	if pids, _ := helpers.GetRunningPIDs(helpers.GetPIDFilePath("supervisor")); len(pids) > 0 {
+		fmt.Println("Restart \"server run\" to apply the change to the running PHP")
+		return false
+	}
+
+	if err := php.Restart(); err != nil {
+		fmt.Printf("Error: %s\n", err)
+		os.Exit(1)
+	}
+
+	return true
+}
@@ -0,0 +1,204 @@
+package php
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"unicode"
+
+	"github.com/alexivashchenko/go-dev-server/env"
+)
+
+// DefaultExtensions are enabled while PHP_EXTENSIONS is not set in .env
+var DefaultExtensions = []string{
+	"curl", "fileinfo", "gd", "intl", "mbstring", "exif", "mysqli", "openssl",
+	"pdo_mysql", "pdo_pgsql", "pdo_sqlite", "pgsql", "sockets", "sqlite3", "xsl", "zip",
+}
+
+// zendExtensions are loaded with zend_extension instead of extension
+var zendExtensions = []string{"opcache", "xdebug"}
+
+// XdebugModes are the values accepted by xdebug.mode
+var XdebugModes = []string{"off", "develop", "coverage", "debug", "gcstats", "profile", "trace"}
+
+// Extension is a loadable library found in the extension directory
+type Extension struct {
+	Name    string
+	File    string
+	Enabled bool
+}
+
+// EnabledExtensions returns the extensions listed in PHP_EXTENSIONS in load order, DefaultExtensions when it is not set
+func EnabledExtensions() []string {
+	value, ok := os.LookupEnv("PHP_EXTENSIONS")
+	if !ok {
+		return slices.Clone(DefaultExtensions)
+	}
+
+	var names []string
+	for _, name := range strings.FieldsFunc(value, isListSeparator) {
+		name = strings.ToLower(name)
+		if !slices.Contains(names, name) {
+			names = append(names, name)
+		}
+	}
+
+	return names
+}
+
+// Extensions returns the extensions available in the extension directory of a PHP version
+func Extensions(config *Configuration) ([]Extension, error) {
+	if config.ExtensionDir == "" {
+		return nil, fmt.Errorf("PHP_EXTENSION_DIR environment variable is not set")
+	}
+
+	entries, err := os.ReadDir(config.ExtensionDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read extension directory: %w", err)
+	}
+
+	enabled := EnabledExtensions()
+	var extensions []Extension
+	for _, entry := range entries {
+		name := extensionName(entry.Name())
+		if entry.IsDir() || name == "" {
+			continue
+		}
+		extensions = append(extensions, Extension{
+			Name:    name,
+			File:    entry.Name(),
+			Enabled: slices.Contains(enabled, name),
+		})
+	}
+
+	return extensions, nil
+}
+
+// SetExtension enables or disables an extension in .env and renders php.ini of every PHP version in use
+func SetExtension(name string, enable bool) error {
+	name = strings.ToLower(name)
+	names := EnabledExtensions()
+
+	if enable {
+		if slices.Contains(names, name) {
+			return fmt.Errorf("extension %s is already enabled", name)
+		}
+
+		config, err := NewConfiguration()
+		if err != nil {
+			return fmt.Errorf("failed to initialize PHP configuration: %w", err)
+		}
+		available, err := Extensions(config)
+		if err != nil {
+			return err
+		}
+		if !slices.ContainsFunc(available, func(extension Extension) bool { return extension.Name == name }) {
+			return fmt.Errorf("extension %s is not available in %s", name, config.ExtensionDir)
+		}
+
+		names = append(names, name)
+	} else {
+		if !slices.Contains(names, name) {
+			return fmt.Errorf("extension %s is not enabled", name)
+		}
+		names = slices.DeleteFunc(names, func(enabled string) bool { return enabled == name })
+	}
+
+	if err := env.Set("PHP_EXTENSIONS", strings.Join(names, ",")); err != nil {
+		return fmt.Errorf("failed to update .env: %w", err)
+	}
+
+	return RenderConfigs()
+}
+
+// SetXdebug turns Xdebug on or off, a non-empty mode replaces XDEBUG_MODE
+func SetXdebug(enable bool, mode string) error {
+	if mode != "" {
+		for _, value := range strings.Split(mode, ",") {
+			if !slices.Contains(XdebugModes, strings.TrimSpace(value)) {
+				return fmt.Errorf("unknown Xdebug mode %q (available: %s)", value, strings.Join(XdebugModes, ", "))
+			}
+		}
+		if err := env.Set("XDEBUG_MODE", mode); err != nil {
+			return fmt.Errorf("failed to update .env: %w", err)
+		}
+	}
+
+	if enable == slices.Contains(EnabledExtensions(), "xdebug") {
+		return RenderConfigs()
+	}
+
+	return SetExtension("xdebug", enable)
+}
+
+// RenderConfigs renders php.ini of every PHP version in use
+func RenderConfigs() error {
+	configs, err := Configurations()
+	if err != nil {
+		return fmt.Errorf("failed to initialize PHP configuration: %w", err)
+	}
+
+	for _, config := range configs {
+		if err := preparePHP(config); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// resolveExtensions returns the php.ini values loading the enabled extensions, skipping the ones a PHP version doesn't have,
+// and whether Xdebug is among them
+func resolveExtensions(config *Configuration) (extensions, zend []string, xdebug bool) {
+	// Without a readable extension directory the names are passed on and PHP reports what is missing
+	available, err := Extensions(config)
+
+	for _, name := range EnabledExtensions() {
+		load := name
+		if err == nil {
+			i := slices.IndexFunc(available, func(extension Extension) bool { return extension.Name == name })
+			if i < 0 {
+				log.Printf("Warning: Extension %s is enabled but not available in %s", name, config.ExtensionDir)
+				continue
+			}
+			// PECL builds such as php_xdebug-3.3.2-8.3-vs16-x64.dll are only found by their file name
+			if strings.Contains(available[i].File, "-") {
+				load = available[i].File
+			}
+		}
+
+		if slices.Contains(zendExtensions, name) {
+			zend = append(zend, load)
+			xdebug = xdebug || name == "xdebug"
+		} else {
+			extensions = append(extensions, load)
+		}
+	}
+
+	return extensions, zend, xdebug
+}
+
+// extensionName returns the name of an extension library: php_intl.dll and intl.so are intl,
+// php_xdebug-3.3.2-8.3-vs16-x64.dll is xdebug, other files have no name
+func extensionName(file string) string {
+	extension := filepath.Ext(file)
+	if extension != ".dll" && extension != ".so" {
+		return ""
+	}
+
+	name := strings.TrimPrefix(strings.TrimSuffix(file, extension), "php_")
+	// PECL builds carry their version in the file name
+	if i := strings.Index(name, "-"); i > 0 {
+		name = name[:i]
+	}
+
+	return strings.ToLower(name)
+}
+
+// isListSeparator reports whether r separates list entries in .env values
+func isListSeparator(r rune) bool {
+	return r == ',' || unicode.IsSpace(r)
+}
@@ -0,0 +1,99 @@
+package php
+
+import (
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestExtensionName(t *testing.T) {
+	tests := []struct {
+		file string
+		want string
+	}{
+		{file: "php_intl.dll", want: "intl"},
+		{file: "intl.so", want: "intl"},
+		{file: "php_PDO_MySQL.dll", want: "pdo_mysql"},
+		{file: "php_xdebug-3.3.2-8.3-vs16-x64.dll", want: "xdebug"},
+		{file: "xdebug-3.3.2.so", want: "xdebug"},
+		{file: "readme.txt", want: ""},
+		{file: "php_intl.dll.bak", want: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			if got := extensionName(tt.file); got != tt.want {
+				t.Errorf("extensionName(%q) = %q, want %q", tt.file, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEnabledExtensions(t *testing.T) {
+	t.Setenv("PHP_EXTENSIONS", "Intl, curl  mbstring,intl,,")
+
+	want := []string{"intl", "curl", "mbstring"}
+	if got := EnabledExtensions(); !slices.Equal(got, want) {
+		t.Errorf("EnabledExtensions() = %v, want %v", got, want)
+	}
+}
+
+func TestResolveExtensions(t *testing.T) {
+	dir := t.TempDir()
+	for _, file := range []string{"php_intl.dll", "php_opcache.dll", "php_xdebug-3.3.2-8.3-vs16-x64.dll", "readme.txt"} {
+		writeFile(t, filepath.Join(dir, file), "")
+	}
+
+	tests := []struct {
+		name          string
+		dir           string
+		enabled       string
+		wantExtension []string
+		wantZend      []string
+		wantXdebug    bool
+	}{
+		{
+			name:          "missing extension skipped",
+			dir:           dir,
+			enabled:       "intl,gd",
+			wantExtension: []string{"intl"},
+		},
+		{
+			name:       "pecl build loaded by file name",
+			dir:        dir,
+			enabled:    "opcache,xdebug",
+			wantZend:   []string{"opcache", "php_xdebug-3.3.2-8.3-vs16-x64.dll"},
+			wantXdebug: true,
+		},
+		{
+			name:          "unreadable directory passes names through",
+			dir:           filepath.Join(dir, "missing"),
+			enabled:       "intl,gd,xdebug",
+			wantExtension: []string{"intl", "gd"},
+			wantZend:      []string{"xdebug"},
+			wantXdebug:    true,
+		},
+		{
+			name:    "nothing enabled",
+			dir:     dir,
+			enabled: "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("PHP_EXTENSIONS", tt.enabled)
+
+			extensions, zend, xdebug := resolveExtensions(&Configuration{ExtensionDir: tt.dir})
+			if !slices.Equal(extensions, tt.wantExtension) {
+				t.Errorf("extensions = %v, want %v", extensions, tt.wantExtension)
+			}
+			if !slices.Equal(zend, tt.wantZend) {
+				t.Errorf("zend extensions = %v, want %v", zend, tt.wantZend)
+			}
+			if xdebug != tt.wantXdebug {
+				t.Errorf("xdebug = %t, want %t", xdebug, tt.wantXdebug)
+			}
+		})
+	}
+}
@@ -1,19 +1,42 @@
 package php
 
 import (
+	"bytes"
 	"fmt"
 	"log"
 	"os"
 	"path/filepath"
 	"runtime"
+	"slices"
+	"sort"
 	"strings"
 	"time"
 
+	"github.com/alexivashchenko/go-dev-server/health"
 	"github.com/alexivashchenko/go-dev-server/helpers"
+	"github.com/alexivashchenko/go-dev-server/launcher"
+	"github.com/alexivashchenko/go-dev-server/logs"
+	"github.com/alexivashchenko/go-dev-server/sites"
+	"github.com/alexivashchenko/go-dev-server/status"
+	"github.com/alexivashchenko/go-dev-server/templates"
 )
 
-// Configuration holds all PHP-related settings
+// DefaultHost and DefaultPort are where the first PHP-CGI worker listens for FastCGI requests
+const (
+	DefaultHost    = "127.0.0.1"
+	DefaultPort    = 9003
+	DefaultWorkers = 2
+)
+
+// UpstreamName is the Nginx upstream sites pass PHP requests to when they don't ask for another version
+const UpstreamName = "php_upstream"
+
+// Configuration holds the settings of one PHP version
 type Configuration struct {
+	// Name identifies the version in worker names and logs, "php" for the default version
+	Name            string
+	Version         string
+	Upstream        string
 	RootDir         string
 	AppFolder       string
 	ErrorLog        string
@@ -27,22 +50,38 @@ type Configuration struct {
 	IniTemplateFile string
 	IniFile         string
 	Host            string
+	// Port is the port of the first worker, the others use the following ports
 	Port            int
+	Workers         int
 	ProcessName     string
+	PIDFile         string
+	ReadyTimeout    time.Duration
 	MailpitSmtpHost string
 	MailpitSmtpPort string
+	// Xdebug holds the [xdebug] settings, Enabled is decided by the enabled extensions
+	Xdebug templates.Xdebug
 }
 
-// NewConfiguration creates a new PHP configuration
+// NewConfiguration creates the configuration of the default PHP version set by PHP_APP_FOLDER
 func NewConfiguration() (*Configuration, error) {
-	rootDir := helpers.GetRootDirectory()
-
 	// Get PHP app folder from environment
 	phpAppFolder := os.Getenv("PHP_APP_FOLDER")
 	if phpAppFolder == "" {
 		return nil, fmt.Errorf("PHP_APP_FOLDER environment variable is not set")
 	}
 
+	config := newConfiguration(phpAppFolder)
+	config.Name = "php"
+	config.Upstream = UpstreamName
+	config.Port = DefaultPort
+
+	return config, nil
+}
+
+// newConfiguration creates the configuration of the PHP version installed in apps/php/<phpAppFolder>
+func newConfiguration(phpAppFolder string) *Configuration {
+	rootDir := helpers.GetRootDirectory()
+
 	// Determine process name based on OS
 	processName := "php-cgi"
 	if runtime.GOOS == "windows" {
@@ -51,12 +90,31 @@ func NewConfiguration() (*Configuration, error) {
 
 	// Create configuration
 	config := &Configuration{
+		Version:      folderVersion(filepath.Join(rootDir, "apps", "php", phpAppFolder)),
 		RootDir:      rootDir,
 		AppFolder:    phpAppFolder,
-		Host:         "127.0.0.1",
-		Port:         9003,
+		Host:         DefaultHost,
+		Workers:      max(1, helpers.GetEnvInt("PHP_WORKERS", DefaultWorkers)),
 		ProcessName:  processName,
+		PIDFile:      helpers.GetPIDFilePath("php"),
+		ReadyTimeout: helpers.GetEnvDuration("PHP_READY_TIMEOUT", 10*time.Second),
 		TemplatesDir: filepath.Join(rootDir, "tpl"),
+		Xdebug: templates.Xdebug{
+			Mode:       os.Getenv("XDEBUG_MODE"),
+			ClientHost: os.Getenv("XDEBUG_CLIENT_HOST"),
+			// Xdebug defaults to 9003, which is taken by the first PHP-CGI worker
+			ClientPort:       helpers.GetEnvInt("XDEBUG_CLIENT_PORT", 9000),
+			StartWithRequest: os.Getenv("XDEBUG_START_WITH_REQUEST"),
+		},
+	}
+	if config.Xdebug.Mode == "" {
+		config.Xdebug.Mode = "debug"
+	}
+	if config.Xdebug.ClientHost == "" {
+		config.Xdebug.ClientHost = "127.0.0.1"
+	}
+	if config.Xdebug.StartWithRequest == "" {
+		config.Xdebug.StartWithRequest = "trigger"
 	}
 
 	// Set paths
@@ -89,53 +147,122 @@ func NewConfiguration() (*Configuration, error) {
 		*configVar = value
 	}
 
-	return config, nil
+	return config
 }
 
-// Start initializes and starts the PHP service
+// Upstreams returns the Nginx upstreams serving PHP requests, one per version
+func Upstreams(configs []*Configuration) []templates.Upstream {
+	// Without a configuration Nginx still needs the upstream the templates refer to
+	if len(configs) == 0 {
+		return []templates.Upstream{
+			{
+				Name:    UpstreamName,
+				Servers: []string{fmt.Sprintf("%s:%d", DefaultHost, DefaultPort)},
+			},
+		}
+	}
+
+	upstreams := make([]templates.Upstream, 0, len(configs))
+	for _, config := range configs {
+		upstreams = append(upstreams, templates.Upstream{
+			Name:    config.Upstream,
+			Servers: config.Addresses(),
+		})
+	}
+
+	return upstreams
+}
+
+// Addresses returns the FastCGI addresses of the workers
+func (c *Configuration) Addresses() []string {
+	addresses := make([]string, 0, c.Workers)
+	for _, port := range c.ports() {
+		addresses = append(addresses, fmt.Sprintf("%s:%d", c.Host, port))
+	}
+
+	return addresses
+}
+
+// UpstreamFor returns the upstream of the first configuration matching the wanted version, empty when none does
+func UpstreamFor(configs []*Configuration, wanted string) string {
+	if config := findConfiguration(configs, wanted); config != nil {
+		return config.Upstream
+	}
+
+	return ""
+}
+
+// Start prepares every PHP version in use and starts the worker manager
 func Start() error {
 	log.Println("Starting PHP service...")
 	startTime := time.Now()
 
 	// Initialize configuration
-	config, err := NewConfiguration()
+	configs, err := Configurations()
 	if err != nil {
 		return fmt.Errorf("failed to initialize PHP configuration: %w", err)
 	}
 
-	// Ensure required directories exist
-	if err := ensureDirectoriesExist(config); err != nil {
-		return fmt.Errorf("failed to create required directories: %w", err)
+	if pids, _ := helpers.GetRunningPIDs(configs[0].PIDFile); len(pids) > 0 {
+		log.Printf("PHP is already running (PID: %d)", pids[0])
+		return nil
 	}
 
-	// Create PHP configuration file
-	if err := createPHPConfig(config); err != nil {
-		return fmt.Errorf("failed to create PHP configuration: %w", err)
+	// Create directories and php.ini files before the workers read them
+	for _, config := range configs {
+		if err := preparePHP(config); err != nil {
+			return err
+		}
 	}
 
-	// Start PHP-CGI process
-	if err := startPHPProcess(config); err != nil {
+	// Start the manager, it starts the workers and respawns the ones that exit
+	if err := startManager(configs[0]); err != nil {
 		return fmt.Errorf("failed to start PHP process: %w", err)
 	}
 
+	// Wait until every worker answers FastCGI requests
+	for _, config := range configs {
+		if err := verifyPHPRunning(config); err != nil {
+			return err
+		}
+	}
+
 	elapsed := time.Since(startTime)
 	log.Printf("PHP service started successfully in %.2f seconds", elapsed.Seconds())
 	return nil
 }
 
-// Stop stops the PHP service
-func Stop() error {
-	log.Println("Stopping PHP service...")
-	startTime := time.Now()
+// Process returns the worker manager to run in the foreground
+func Process() (launcher.Spec, error) {
+	return managerCommand()
+}
 
-	config, err := NewConfiguration()
+// Ready waits until the PHP-CGI process of every PHP version answers FastCGI requests
+func Ready() error {
+	configs, err := Configurations()
 	if err != nil {
 		return fmt.Errorf("failed to initialize PHP configuration: %w", err)
 	}
 
-	// Kill PHP-CGI process
-	if err := helpers.KillProcess(config.ProcessName); err != nil {
-		return fmt.Errorf("failed to stop PHP process: %w", err)
+	for _, config := range configs {
+		if err := verifyPHPRunning(config); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Stop stops the worker manager and the workers of all PHP versions
+func Stop() error {
+	log.Println("Stopping PHP service...")
+	startTime := time.Now()
+
+	// The manager goes first so it doesn't respawn the workers
+	for _, pidFile := range pidFiles() {
+		if err := helpers.StopPIDFile(pidFile, 5*time.Second); err != nil {
+			return fmt.Errorf("failed to stop PHP process: %w", err)
+		}
 	}
 
 	elapsed := time.Since(startTime)
@@ -143,6 +270,12 @@ func Stop() error {
 	return nil
 }
 
+// pidFiles returns the PID file of the manager followed by the ones of the workers, including workers no site needs anymore
+func pidFiles() []string {
+	workers, _ := filepath.Glob(helpers.GetPIDFilePath("php-*"))
+	return append([]string{helpers.GetPIDFilePath("php")}, workers...)
+}
+
 // Restart restarts the PHP service
 func Restart() error {
 	log.Println("Restarting PHP service...")
@@ -162,18 +295,116 @@ func Restart() error {
 	return nil
 }
 
-// GetStatus returns the current status of the PHP service
-func GetStatus() string {
-	config, err := NewConfiguration()
+// Reload restarts the workers when sites started or stopped asking for a PHP version or php.ini changed
+func Reload() error {
+	configs, err := Configurations()
 	if err != nil {
-		return "Error: " + err.Error()
+		return fmt.Errorf("failed to initialize PHP configuration: %w", err)
 	}
 
-	running, pid := helpers.IsProcessRunning(config.ProcessName)
-	if running {
-		return fmt.Sprintf("Running (PID: %d, Port: %d)", pid, config.Port)
+	if pids, _ := helpers.GetRunningPIDs(configs[0].PIDFile); len(pids) == 0 {
+		log.Println("PHP is not running, nothing to reload")
+		return nil
 	}
-	return "Stopped"
+
+	var wanted []string
+	for _, config := range configs {
+		for _, port := range config.ports() {
+			wanted = append(wanted, helpers.GetPIDFilePath(workerName(config, port)))
+		}
+	}
+	running := pidFiles()[1:]
+	sort.Strings(wanted)
+	sort.Strings(running)
+
+	// PHP-CGI reads php.ini when it starts, e.g. changed per-site sections need new workers
+	iniChanged := false
+	for _, config := range configs {
+		previous, _ := os.ReadFile(config.IniFile)
+		if err := preparePHP(config); err != nil {
+			return err
+		}
+		current, err := os.ReadFile(config.IniFile)
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", config.IniFile, err)
+		}
+		iniChanged = iniChanged || !bytes.Equal(previous, current)
+	}
+
+	if slices.Equal(wanted, running) && !iniChanged {
+		log.Println("PHP versions and php.ini unchanged, keeping the PHP workers")
+		return nil
+	}
+
+	// Under server run the supervisor starts the manager again by itself
+	if pids, _ := helpers.GetRunningPIDs(helpers.GetPIDFilePath("supervisor")); len(pids) > 0 {
+		return Stop()
+	}
+
+	return Restart()
+}
+
+// GetStatus returns the current status of the PHP service with the PIDs of the manager and its workers
+func GetStatus() status.Status {
+	configs, err := Configurations()
+	if err != nil {
+		return status.FromError(err)
+	}
+
+	result := status.FromPIDFile(configs[0].PIDFile)
+	var versions []string
+	var probes []health.Probe
+	workers, running := 0, 0
+	var stopped []string
+	for _, config := range configs {
+		for _, port := range config.ports() {
+			workers++
+			name := workerName(config, port)
+			if pids, _ := helpers.GetRunningPIDs(helpers.GetPIDFilePath(name)); len(pids) > 0 {
+				result.PIDs = append(result.PIDs, pids...)
+				running++
+			} else if lastError := status.LastError(name); lastError != "" {
+				stopped = append(stopped, name+": "+lastError)
+			}
+		}
+
+		result.Addresses = append(result.Addresses, config.Addresses()...)
+		if version := status.Version(filepath.Join(config.AppDir, config.ProcessName), "-v"); version != "" {
+			versions = append(versions, version)
+		}
+		probes = append(probes, readinessProbe(config))
+	}
+
+	result.Version = strings.Join(versions, ", ")
+	if result.State == status.Running {
+		result.Details = fmt.Sprintf("%d of %d workers running", running, workers)
+	}
+	result.CheckHealth(health.All(probes...))
+
+	// The manager keeps running when a worker gave up after crashing, its port answers with 502
+	if result.State == status.Running && running < workers {
+		result.Health = fmt.Sprintf("%s: %d of %d workers stopped", status.Unhealthy, workers-running, workers)
+		if len(stopped) > 0 {
+			result.LastError = strings.Join(stopped, "; ")
+		}
+	}
+
+	return result
+}
+
+// preparePHP creates the required directories and the php.ini file
+func preparePHP(config *Configuration) error {
+	// Ensure required directories exist
+	if err := ensureDirectoriesExist(config); err != nil {
+		return fmt.Errorf("failed to create required directories: %w", err)
+	}
+
+	// Create PHP configuration file
+	if err := createPHPConfig(config); err != nil {
+		return fmt.Errorf("failed to create PHP configuration: %w", err)
+	}
+
+	return nil
 }
 
 // ensureDirectoriesExist creates necessary directories
@@ -210,57 +441,101 @@ func ensureDirectoriesExist(config *Configuration) error {
 	return nil
 }
 
-// createPHPConfig creates the PHP configuration file
+// createPHPConfig renders the PHP configuration file from the template
 func createPHPConfig(config *Configuration) error {
 	// Check if template exists
 	if _, err := os.Stat(config.IniTemplateFile); os.IsNotExist(err) {
 		return fmt.Errorf("PHP ini template file not found: %s", config.IniTemplateFile)
 	}
 
-	// Copy template to destination
-	if err := helpers.CopyFile(config.IniTemplateFile, config.IniFile); err != nil {
-		return fmt.Errorf("failed to copy PHP ini template: %w", err)
+	data := templates.NewData(config.RootDir)
+	data.PHP = templates.PHP{
+		AppFolder:       config.AppFolder,
+		ErrorLog:        config.ErrorLog,
+		IncludePath:     config.IncludePath,
+		ExtensionDir:    config.ExtensionDir,
+		SessionSavePath: config.SessionSavePath,
+		CurlCaInfo:      config.CurlCaInfo,
+		SendmailPath:    config.SendmailPath,
+		Xdebug:          config.Xdebug,
 	}
+	data.PHP.Extensions, data.PHP.ZendExtensions, data.PHP.Xdebug.Enabled = resolveExtensions(config)
 
-	// Replace placeholders in the ini file
-	replaceMap := map[string]string{
-		"{PHP_APP_FOLDER}":        config.AppFolder,
-		"{PHP_ERROR_LOG}":         config.ErrorLog,
-		"{PHP_INCLUDE_PATH}":      config.IncludePath,
-		"{PHP_EXTENSION_DIR}":     config.ExtensionDir,
-		"{PHP_SESSION_SAVE_PATH}": config.SessionSavePath,
-		"{PHP_CURL_CAINFO}":       config.CurlCaInfo,
-		"{PHP_SENDMAIL_PATH}":     config.SendmailPath,
-		"{MAILPIT_SMTP_HOST}":     config.MailpitSmtpHost,
-		"{MAILPIT_SMTP_PORT}":     config.MailpitSmtpPort,
+	sections, err := siteSections()
+	if err != nil {
+		return err
+	}
+	data.PHP.Sections = sections
+	data.Mailpit = templates.Mailpit{
+		SMTPHost: config.MailpitSmtpHost,
+		SMTPPort: config.MailpitSmtpPort,
 	}
 
-	if err := helpers.ReplaceInFileByMap(config.IniFile, replaceMap); err != nil {
-		return fmt.Errorf("failed to update PHP ini file: %w", err)
+	if err := templates.RenderToFile(config.IniTemplateFile, config.IniFile, data); err != nil {
+		return fmt.Errorf("failed to render PHP ini file: %w", err)
 	}
 
 	return nil
 }
 
-// startPHPProcess starts the PHP-CGI process
-func startPHPProcess(config *Configuration) error {
-	// Prepare command
-	command := fmt.Sprintf("%s -b %s:%d", config.ProcessName, config.Host, config.Port)
+// siteSections returns the php.ini sections overriding settings for the sites configured with PHP_INI_ keys,
+// PHP-CGI applies [HOST=...] by the domain of the request and [PATH=...] by the folder of the script
+func siteSections() ([]templates.INISection, error) {
+	siteList, err := sites.All()
+	if err != nil {
+		return nil, err
+	}
+
+	var sections []templates.INISection
+	for _, site := range siteList {
+		if len(site.PHPIni) == 0 || site.Root() == "" {
+			continue
+		}
 
-	// Set working directory to PHP app directory for proper DLL loading
-	workingDir := config.AppDir
+		values := make(map[string]string, len(site.PHPIni))
+		for directive, value := range site.PHPIni {
+			values[directive] = sites.IniValue(value)
+		}
 
-	// Start PHP-CGI process
-	if err := helpers.RunCommandInDirectory(command, workingDir, true); err != nil {
-		return fmt.Errorf("failed to start PHP-CGI: %w", err)
+		// HOST covers the domain and its aliases, Nginx passes the main domain as SERVER_NAME,
+		// PATH covers requests reaching the site through another name, e.g. the IP address
+		sections = append(sections,
+			templates.INISection{Name: "HOST=" + site.Domain, Values: values},
+			templates.INISection{Name: "PATH=" + strings.TrimSuffix(helpers.ReplaceBackslashToSlash(site.Root()), "/"), Values: values},
+		)
 	}
 
-	// Verify process is running
-	time.Sleep(500 * time.Millisecond) // Give process time to start
-	running, _ := helpers.IsProcessRunning(config.ProcessName)
-	if !running {
-		return fmt.Errorf("PHP-CGI process failed to start")
+	return sections, nil
+}
+
+// verifyPHPRunning pings the workers of a PHP version over FastCGI until they respond
+func verifyPHPRunning(config *Configuration) error {
+	if err := health.WaitFor(readinessProbe(config), config.ReadyTimeout); err != nil {
+		return fmt.Errorf("%s is not answering: %w", config.AppFolder, err)
 	}
 
 	return nil
 }
+
+// readinessProbe checks that the recorded manager is alive and every worker answers a FastCGI ping
+func readinessProbe(config *Configuration) health.Probe {
+	probes := []health.Probe{health.PIDFile(config.PIDFile)}
+	for _, address := range config.Addresses() {
+		probes = append(probes, health.FastCGI(address))
+	}
+
+	return health.All(probes...)
+}
+
+// LogFiles returns the PHP error log and the captured output of the workers
+func LogFiles() []string {
+	var files []string
+
+	config, err := NewConfiguration()
+	if err == nil && config.ErrorLog != "" {
+		files = append(files, config.ErrorLog)
+	}
+
+	others, _ := filepath.Glob(logs.OutputFile("php-*"))
+	return append(files, others...)
+}
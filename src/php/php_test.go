@@ -0,0 +1,85 @@
+package php
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/alexivashchenko/go-dev-server/helpers"
+)
+
+// writeFile creates a file and its folders for a test
+func writeFile(t *testing.T, filename, content string) {
+	t.Helper()
+
+	if err := os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filename, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCreatePHPConfigRendersSiteSections(t *testing.T) {
+	root := t.TempDir()
+	if err := helpers.SetRootDirectory(root); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("NGINX_DOMAIN_TAIL", "test")
+	t.Setenv("PHP_APP_FOLDER", "php-8.3.16")
+	t.Setenv("PHP_EXTENSIONS", "")
+
+	template, err := os.ReadFile(filepath.Join("..", "..", "tpl", "php", "php.ini.tpl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, filepath.Join(root, "tpl", "php", "php.ini.tpl"), string(template))
+	writeFile(t, filepath.Join(root, "www", "shop", "public", "index.php"), "")
+	writeFile(t, filepath.Join(root, "www", "shop", ".devserver"),
+		"DOCROOT='public'\nPHP_INI_memory_limit='1G'\nPHP_INI_upload_max_filesize='500M'\nPHP_INI_include_path='.;C:/php/pear'\n")
+	writeFile(t, filepath.Join(root, "www", "plain", "index.php"), "")
+
+	config, err := NewConfiguration()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(config.AppDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := createPHPConfig(config); err != nil {
+		t.Fatal(err)
+	}
+
+	content, err := os.ReadFile(config.IniFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ini := string(content)
+
+	values := "\ninclude_path=\".;C:/php/pear\"\nmemory_limit=1G\nupload_max_filesize=500M\n"
+	docRoot := helpers.ReplaceBackslashToSlash(filepath.Join(root, "www", "shop", "public"))
+	tests := []struct {
+		name    string
+		section string
+	}{
+		{name: "host section", section: "\n[HOST=shop.test]" + values},
+		{name: "path section", section: "\n[PATH=" + docRoot + "]" + values},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if !strings.Contains(ini, tt.section) {
+				t.Errorf("php.ini is missing %q", tt.section)
+			}
+		})
+	}
+
+	if strings.Contains(ini, "plain.test") {
+		t.Error("php.ini has a section for a site without PHP_INI_ settings")
+	}
+	// Every directive after a section belongs to it, so the sections come last
+	if index := strings.Index(ini, "\n[HOST="); strings.Contains(ini[index:], "\n[xdebug]") {
+		t.Error("php.ini has sections after the per-site sections")
+	}
+}
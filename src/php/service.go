@@ -0,0 +1,59 @@
+package php
+
+import (
+	"github.com/alexivashchenko/go-dev-server/launcher"
+	"github.com/alexivashchenko/go-dev-server/status"
+)
+
+// Service exposes the PHP package to the service registry
+type Service struct{}
+
+// Name returns the service name used on the command line and in .env
+func (Service) Name() string {
+	return "PHP"
+}
+
+// DependsOn returns the services that must be started before PHP
+func (Service) DependsOn() []string {
+	return []string{"Mailpit"}
+}
+
+// Start starts the PHP service
+func (Service) Start() error {
+	return Start()
+}
+
+// Stop stops the PHP service
+func (Service) Stop() error {
+	return Stop()
+}
+
+// Restart restarts the PHP service
+func (Service) Restart() error {
+	return Restart()
+}
+
+// Reload starts and stops PHP versions to match the sites
+func (Service) Reload() error {
+	return Reload()
+}
+
+// Status returns the current status of the PHP service
+func (Service) Status() status.Status {
+	return GetStatus()
+}
+
+// Process returns the foreground process used by run mode
+func (Service) Process() (launcher.Spec, error) {
+	return Process()
+}
+
+// Ready waits until PHP accepts requests
+func (Service) Ready() error {
+	return Ready()
+}
+
+// LogFiles returns the log files written by PHP itself
+func (Service) LogFiles() []string {
+	return LogFiles()
+}
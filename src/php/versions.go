@@ -0,0 +1,242 @@
+package php
+
+import (
+	"fmt"
+	"log"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/alexivashchenko/go-dev-server/env"
+	"github.com/alexivashchenko/go-dev-server/helpers"
+	"github.com/alexivashchenko/go-dev-server/sites"
+	"github.com/alexivashchenko/go-dev-server/status"
+)
+
+// Installation is a PHP version found in apps/php
+type Installation struct {
+	Folder  string
+	Version string
+}
+
+// Installed returns the PHP versions in apps/php, oldest first
+func Installed() ([]Installation, error) {
+	dir := filepath.Join(helpers.GetRootDirectory(), "apps", "php")
+	folders, err := helpers.ListDirectories(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var installations []Installation
+	for _, folder := range folders {
+		version := folderVersion(filepath.Join(dir, folder))
+		if version == "" {
+			continue
+		}
+		installations = append(installations, Installation{Folder: folder, Version: version})
+	}
+
+	sort.Slice(installations, func(i, j int) bool {
+		return compareVersions(installations[i].Version, installations[j].Version) < 0
+	})
+
+	return installations, nil
+}
+
+var (
+	versionsMu sync.Mutex
+	// versions caches the version of every app folder, asking the binaries starts a process
+	versions = map[string]string{}
+)
+
+// folderVersion returns the version of the PHP in an app folder, every part of the server resolves versions here
+func folderVersion(appDir string) string {
+	versionsMu.Lock()
+	defer versionsMu.Unlock()
+
+	if version, ok := versions[appDir]; ok {
+		return version
+	}
+
+	// The folder name is only a hint, a renamed folder still reports its real version
+	version := detectVersion(appDir)
+	if version == "" {
+		version = versionPattern.FindString(filepath.Base(appDir))
+	}
+	versions[appDir] = version
+
+	return version
+}
+
+// detectVersion asks the PHP binaries of an app folder for their version, php-cgi when there is no CLI
+func detectVersion(appDir string) string {
+	for _, name := range []string{"php", "php-cgi"} {
+		if runtime.GOOS == "windows" {
+			name += ".exe"
+		}
+		if version := versionPattern.FindString(status.Version(filepath.Join(appDir, name), "-v")); version != "" {
+			return version
+		}
+	}
+
+	return ""
+}
+
+// versionPattern finds the PHP version in an app folder name such as php-8.3.16-Win32-vs16-x64
+var versionPattern = regexp.MustCompile(`\d+\.\d+(\.\d+)?`)
+
+// FindInstallation returns the installation matching a folder name, a version such as 8.3 or 8.3.16,
+// or a part of a folder name, the newest one when several match
+func FindInstallation(installations []Installation, wanted string) (Installation, error) {
+	for _, installation := range installations {
+		if strings.EqualFold(installation.Folder, wanted) {
+			return installation, nil
+		}
+	}
+
+	for i := len(installations) - 1; i >= 0; i-- {
+		if matchesVersion(installations[i].Version, wanted) {
+			return installations[i], nil
+		}
+	}
+
+	for i := len(installations) - 1; i >= 0; i-- {
+		if strings.Contains(strings.ToLower(installations[i].Folder), strings.ToLower(wanted)) {
+			return installations[i], nil
+		}
+	}
+
+	var available []string
+	for _, installation := range installations {
+		available = append(available, installation.Version)
+	}
+	return Installation{}, fmt.Errorf("PHP %s is not installed in apps/php (available: %s)", wanted, strings.Join(available, ", "))
+}
+
+// matchesVersion reports whether version is the wanted version or one of its releases, "8.3" matches "8.3.16"
+func matchesVersion(version, wanted string) bool {
+	return version == wanted || strings.HasPrefix(version, wanted+".")
+}
+
+// compareVersions compares dotted version numbers numerically, "8.10" is newer than "8.9"
+func compareVersions(a, b string) int {
+	partsA, partsB := strings.Split(a, "."), strings.Split(b, ".")
+	for i := 0; i < len(partsA) || i < len(partsB); i++ {
+		var numberA, numberB int
+		if i < len(partsA) {
+			numberA, _ = strconv.Atoi(partsA[i])
+		}
+		if i < len(partsB) {
+			numberB, _ = strconv.Atoi(partsB[i])
+		}
+		if numberA != numberB {
+			return numberA - numberB
+		}
+	}
+
+	return 0
+}
+
+// Configurations returns the default PHP version followed by every other installed version a site asks for
+func Configurations() ([]*Configuration, error) {
+	config, err := NewConfiguration()
+	if err != nil {
+		return nil, err
+	}
+
+	siteList, err := sites.All()
+	if err != nil {
+		return nil, err
+	}
+
+	// Sites are matched like "server php use" and "server php list" see the versions
+	installed, _ := Installed()
+
+	configs := []*Configuration{config}
+	for _, site := range siteList {
+		if site.PHPVersion == "" || findConfiguration(configs, site.PHPVersion) != nil {
+			continue
+		}
+
+		installation, err := FindInstallation(installed, site.PHPVersion)
+		if err != nil {
+			log.Printf("Warning: %s asks for PHP %s which is not installed in apps/php, %s is used", site.Domain, site.PHPVersion, config.AppFolder)
+			continue
+		}
+		if slices.ContainsFunc(configs, func(c *Configuration) bool { return c.AppFolder == installation.Folder }) {
+			continue
+		}
+
+		configs = append(configs, newConfiguration(installation.Folder))
+	}
+
+	// Ports follow the version order so they stay the same while the requested versions don't change
+	others := configs[1:]
+	sort.Slice(others, func(i, j int) bool {
+		return compareVersions(others[i].Version, others[j].Version) < 0
+	})
+	for i, other := range others {
+		other.Name = "php-" + other.Version
+		other.Upstream = "php_" + strings.ReplaceAll(other.Version, ".", "_") + "_upstream"
+		other.Port = DefaultPort + (i+1)*config.Workers
+	}
+
+	return configs, nil
+}
+
+// findConfiguration returns the configuration FindInstallation picks among the versions in use
+func findConfiguration(configs []*Configuration, wanted string) *Configuration {
+	installations := make([]Installation, 0, len(configs))
+	for _, config := range configs {
+		installations = append(installations, Installation{Folder: config.AppFolder, Version: config.Version})
+	}
+	sort.SliceStable(installations, func(i, j int) bool {
+		return compareVersions(installations[i].Version, installations[j].Version) < 0
+	})
+
+	installation, err := FindInstallation(installations, wanted)
+	if err != nil {
+		return nil
+	}
+
+	for _, config := range configs {
+		if config.AppFolder == installation.Folder {
+			return config
+		}
+	}
+
+	return nil
+}
+
+// Use makes the matching installation the default PHP version in .env and renders its php.ini
+func Use(wanted string) (Installation, error) {
+	installations, err := Installed()
+	if err != nil {
+		return Installation{}, fmt.Errorf("failed to list PHP versions: %w", err)
+	}
+
+	installation, err := FindInstallation(installations, wanted)
+	if err != nil {
+		return Installation{}, err
+	}
+
+	if err := env.Set("PHP_APP_FOLDER", installation.Folder); err != nil {
+		return Installation{}, fmt.Errorf("failed to update .env: %w", err)
+	}
+
+	config, err := NewConfiguration()
+	if err != nil {
+		return Installation{}, fmt.Errorf("failed to initialize PHP configuration: %w", err)
+	}
+
+	if err := preparePHP(config); err != nil {
+		return Installation{}, err
+	}
+
+	return installation, nil
+}
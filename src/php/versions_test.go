@@ -0,0 +1,153 @@
+package php
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/alexivashchenko/go-dev-server/helpers"
+)
+
+func TestCompareVersions(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{a: "8.10", b: "8.9", want: 1},
+		{a: "8.9", b: "8.10", want: -1},
+		{a: "8.3", b: "8.3.0", want: 0},
+		{a: "8.3.16", b: "8.3.2", want: 1},
+		{a: "7.4.33", b: "8.0.0", want: -1},
+		{a: "8.3.16", b: "8.3.16", want: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.a+" vs "+tt.b, func(t *testing.T) {
+			got := compareVersions(tt.a, tt.b)
+			if (got > 0) != (tt.want > 0) || (got < 0) != (tt.want < 0) {
+				t.Errorf("compareVersions(%q, %q) = %d, want sign of %d", tt.a, tt.b, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMatchesVersion(t *testing.T) {
+	tests := []struct {
+		version, wanted string
+		want            bool
+	}{
+		{version: "8.3.16", wanted: "8.3.16", want: true},
+		{version: "8.3.16", wanted: "8.3", want: true},
+		{version: "8.3.16", wanted: "8", want: true},
+		{version: "8.3.16", wanted: "8.3.1", want: false},
+		{version: "8.10.0", wanted: "8.1", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.version+" "+tt.wanted, func(t *testing.T) {
+			if got := matchesVersion(tt.version, tt.wanted); got != tt.want {
+				t.Errorf("matchesVersion(%q, %q) = %t, want %t", tt.version, tt.wanted, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFindInstallation(t *testing.T) {
+	installations := []Installation{
+		{Folder: "php-7.4.33", Version: "7.4.33"},
+		{Folder: "php-8.3.2", Version: "8.3.2"},
+		{Folder: "php-8.3.16", Version: "8.3.16"},
+		{Folder: "php83-nts", Version: "8.3.20"},
+		{Folder: "php-8.10.0", Version: "8.10.0"},
+	}
+
+	tests := []struct {
+		wanted     string
+		wantFolder string
+		wantErr    bool
+	}{
+		{wanted: "php-8.3.2", wantFolder: "php-8.3.2"},
+		{wanted: "PHP-7.4.33", wantFolder: "php-7.4.33"},
+		{wanted: "8.3", wantFolder: "php83-nts"},
+		{wanted: "8.3.16", wantFolder: "php-8.3.16"},
+		{wanted: "8", wantFolder: "php-8.10.0"},
+		{wanted: "nts", wantFolder: "php83-nts"},
+		{wanted: "8.2", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.wanted, func(t *testing.T) {
+			got, err := FindInstallation(installations, tt.wanted)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("FindInstallation(%q) error = %v, wantErr %v", tt.wanted, err, tt.wantErr)
+			}
+			if got.Folder != tt.wantFolder {
+				t.Errorf("FindInstallation(%q) = %q, want %q", tt.wanted, got.Folder, tt.wantFolder)
+			}
+		})
+	}
+}
+
+func TestFindInstallationListsAvailableVersions(t *testing.T) {
+	_, err := FindInstallation([]Installation{{Folder: "php-8.3.16", Version: "8.3.16"}}, "7.4")
+	if err == nil || !strings.Contains(err.Error(), "available: 8.3.16") {
+		t.Errorf("FindInstallation() error = %v, want the available versions", err)
+	}
+}
+
+func TestInstalledSortsByVersion(t *testing.T) {
+	root := t.TempDir()
+	if err := helpers.SetRootDirectory(root); err != nil {
+		t.Fatal(err)
+	}
+	// Without binaries the version comes from the folder name, folders without one are skipped
+	for _, folder := range []string{"php-8.10.0", "php-8.9.1", "php-7.4.33", "tools"} {
+		if err := os.MkdirAll(filepath.Join(root, "apps", "php", folder), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	installations, err := Installed()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	for _, installation := range installations {
+		got = append(got, installation.Version)
+	}
+	if want := "7.4.33 8.9.1 8.10.0"; strings.Join(got, " ") != want {
+		t.Errorf("Installed() = %v, want %s", got, want)
+	}
+}
+
+func TestFindConfiguration(t *testing.T) {
+	configs := []*Configuration{
+		{AppFolder: "php-8.3.16", Version: "8.3.16"},
+		{AppFolder: "php-8.10.0", Version: "8.10.0"},
+		{AppFolder: "php-8.9.1", Version: "8.9.1"},
+	}
+
+	tests := []struct {
+		wanted string
+		want   string
+	}{
+		{wanted: "8", want: "php-8.10.0"},
+		{wanted: "8.9", want: "php-8.9.1"},
+		{wanted: "php-8.3.16", want: "php-8.3.16"},
+		{wanted: "7.4", want: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.wanted, func(t *testing.T) {
+			var got string
+			if config := findConfiguration(configs, tt.wanted); config != nil {
+				got = config.AppFolder
+			}
+			if got != tt.want {
+				t.Errorf("findConfiguration(%q) = %q, want %q", tt.wanted, got, tt.want)
+			}
+		})
+	}
+}
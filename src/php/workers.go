@@ -0,0 +1,113 @@
+package php
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/alexivashchenko/go-dev-server/health"
+	"github.com/alexivashchenko/go-dev-server/helpers"
+	"github.com/alexivashchenko/go-dev-server/launcher"
+	"github.com/alexivashchenko/go-dev-server/logs"
+	"github.com/alexivashchenko/go-dev-server/supervisor"
+)
+
+// ManagerCommand is the hidden server command running the PHP-CGI workers
+const ManagerCommand = "php-workers"
+
+// RunWorkers runs the workers of every PHP version in use and respawns the ones that exit until ctx is cancelled
+func RunWorkers(ctx context.Context) error {
+	configs, err := Configurations()
+	if err != nil {
+		return fmt.Errorf("failed to initialize PHP configuration: %w", err)
+	}
+
+	var processes []supervisor.Process
+	for _, config := range configs {
+		if err := preparePHP(config); err != nil {
+			return err
+		}
+
+		for _, port := range config.ports() {
+			address := fmt.Sprintf("%s:%d", config.Host, port)
+			processes = append(processes, supervisor.Process{
+				Name:    workerName(config, port),
+				PIDFile: helpers.GetPIDFilePath(workerName(config, port)),
+				Command: func() (launcher.Spec, error) {
+					return workerCommand(config, port), nil
+				},
+				Ready: func() error {
+					return health.WaitFor(health.FastCGI(address), config.ReadyTimeout)
+				},
+			})
+		}
+	}
+
+	// Workers exit on purpose after PHP_FCGI_MAX_REQUESTS, such an exit is a respawn and not a crash
+	options := supervisor.DefaultOptions()
+	options.InitialBackoff = 100 * time.Millisecond
+	options.StableAfter = 5 * time.Second
+	options.RespawnCleanExits = true
+
+	return supervisor.New(processes, options).Run(ctx)
+}
+
+// startManager starts the worker manager in the background and records its PID
+func startManager(config *Configuration) error {
+	spec, err := managerCommand()
+	if err != nil {
+		return err
+	}
+
+	cmd, err := launcher.StartBackground(spec, 500*time.Millisecond)
+	if err != nil {
+		return fmt.Errorf("failed to start PHP worker manager: %w", err)
+	}
+
+	if err := helpers.WritePIDFile(config.PIDFile, cmd.Process.Pid); err != nil {
+		return fmt.Errorf("failed to record PHP worker manager PID: %w", err)
+	}
+
+	return nil
+}
+
+// managerCommand returns this executable running the worker manager in the foreground
+func managerCommand() (launcher.Spec, error) {
+	executable, err := os.Executable()
+	if err != nil {
+		return launcher.Spec{}, fmt.Errorf("failed to locate executable: %w", err)
+	}
+
+	return logs.Capture(launcher.Spec{
+		Executable: executable,
+		Args:       []string{"--root=" + helpers.GetRootDirectory(), ManagerCommand},
+	}, "php"), nil
+}
+
+// workerCommand returns the process running a php-cgi worker in the foreground, each worker captures into its own file
+func workerCommand(config *Configuration, port int) launcher.Spec {
+	return logs.Capture(launcher.Spec{
+		Executable: filepath.Join(config.AppDir, config.ProcessName),
+		Args:       []string{"-b", fmt.Sprintf("%s:%d", config.Host, port)},
+		// Run from the PHP app directory for proper DLL loading
+		Dir: config.AppDir,
+	}, workerName(config, port))
+}
+
+// workerName returns the name of a worker in logs and PID files, e.g. php-9003 or php-8.1.30-9005
+func workerName(config *Configuration, port int) string {
+	return config.Name + "-" + strconv.Itoa(port)
+}
+
+// ports returns the ports of the workers
+func (c *Configuration) ports() []int {
+	ports := make([]int, 0, c.Workers)
+	for port := c.Port; port < c.Port+c.Workers; port++ {
+		ports = append(ports, port)
+	}
+
+	return ports
+}
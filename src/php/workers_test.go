@@ -0,0 +1,39 @@
+package php
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/alexivashchenko/go-dev-server/helpers"
+)
+
+func TestWorkerCommandCapturesPerWorker(t *testing.T) {
+	root := t.TempDir()
+	if err := helpers.SetRootDirectory(root); err != nil {
+		t.Fatal(err)
+	}
+
+	configs := []*Configuration{
+		{Name: "php", AppDir: filepath.Join(root, "apps", "php", "php-8.3.16"), ProcessName: "php-cgi", Host: DefaultHost},
+		{Name: "php-8.1.30", AppDir: filepath.Join(root, "apps", "php", "php-8.1.30"), ProcessName: "php-cgi", Host: DefaultHost},
+	}
+
+	manager, err := managerCommand()
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{manager.Stdout: "manager"}
+
+	for _, config := range configs {
+		for _, port := range []int{DefaultPort, DefaultPort + 1} {
+			spec := workerCommand(config, port)
+			if spec.Stdout != spec.Stderr {
+				t.Errorf("%s: stdout %s and stderr %s differ", workerName(config, port), spec.Stdout, spec.Stderr)
+			}
+			if other, ok := files[spec.Stdout]; ok {
+				t.Errorf("%s captures into %s like %s", workerName(config, port), spec.Stdout, other)
+			}
+			files[spec.Stdout] = workerName(config, port)
+		}
+	}
+}
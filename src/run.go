@@ -0,0 +1,145 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+
+	"github.com/alexivashchenko/go-dev-server/helpers"
+	"github.com/alexivashchenko/go-dev-server/launcher"
+	"github.com/alexivashchenko/go-dev-server/php"
+	"github.com/alexivashchenko/go-dev-server/service"
+	"github.com/alexivashchenko/go-dev-server/supervisor"
+)
+
+// runServices runs services under the supervisor until Ctrl+C or SIGTERM
+func runServices(services []service.Service) {
+	waves, err := service.Waves(services)
+	if err != nil {
+		fmt.Printf("Error: %s\n", err)
+		os.Exit(1)
+	}
+
+	pidFile := helpers.GetPIDFilePath("supervisor")
+	if pids, _ := helpers.GetRunningPIDs(pidFile); len(pids) > 0 {
+		fmt.Printf("Error: supervisor is already running (PID: %d)\n", pids[0])
+		os.Exit(1)
+	}
+
+	// Services started with server start would block the ports and lose their PID files to the supervised ones
+	for _, s := range services {
+		if _, ok := s.(service.Supervised); !ok {
+			continue
+		}
+		if pids, _ := helpers.GetRunningPIDs(helpers.GetPIDFilePath(s.Name())); len(pids) > 0 {
+			fmt.Printf("Error: %s is already running (PID: %d), stop it first with ./server stop %s\n", s.Name(), pids[0], strings.ToLower(s.Name()))
+			os.Exit(1)
+		}
+	}
+
+	// Services without a process (SSL) are one-shot and run before anything is supervised
+	var processes []supervisor.Process
+	for _, wave := range waves {
+		for _, s := range wave {
+			supervised, ok := s.(service.Supervised)
+			if !ok {
+				fmt.Printf("Starting %s...\n", s.Name())
+				if err := s.Start(); err != nil {
+					fmt.Printf("failed to start %s: %v\n", s.Name(), err)
+					os.Exit(1)
+				}
+				continue
+			}
+			processes = append(processes, supervisor.Process{
+				Name:    s.Name(),
+				PIDFile: helpers.GetPIDFilePath(s.Name()),
+				Command: supervised.Process,
+				Ready:   supervised.Ready,
+			})
+		}
+	}
+
+	if err := helpers.WritePIDFile(pidFile, os.Getpid()); err != nil {
+		fmt.Printf("Error: %s\n", err)
+		os.Exit(1)
+	}
+	defer helpers.RemoveFile(pidFile)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	fmt.Println("Supervising services, press Ctrl+C to stop...")
+	if err := supervisor.New(processes, supervisor.DefaultOptions()).Run(ctx); err != nil {
+		fmt.Printf("Error: %s\n", err)
+		helpers.RemoveFile(pidFile)
+		os.Exit(1)
+	}
+
+	fmt.Println("Services stopped")
+}
+
+// runPHPWorkers runs the PHP worker manager started by the PHP service until it is stopped
+func runPHPWorkers() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if err := php.RunWorkers(ctx); err != nil {
+		fmt.Printf("Error: %s\n", err)
+		os.Exit(1)
+	}
+}
+
+// detachSupervisor starts run mode as a background process and returns immediately
+func detachSupervisor(args []string) {
+	executable, err := os.Executable()
+	if err != nil {
+		fmt.Printf("Error: failed to locate executable: %s\n", err)
+		os.Exit(1)
+	}
+
+	// Pass everything through except the detach flag itself
+	runArgs := []string{"run"}
+	for _, arg := range args {
+		if strings.TrimLeft(arg, "-") != "detach" {
+			runArgs = append(runArgs, arg)
+		}
+	}
+
+	logFile := filepath.Join(helpers.GetRootDirectory(), "logs", "supervisor.log")
+	cmd, err := launcher.StartDetached(launcher.Spec{
+		Executable: executable,
+		Args:       runArgs,
+		Stdout:     logFile,
+		Stderr:     logFile,
+	})
+	if err != nil {
+		fmt.Printf("Error: failed to start supervisor: %s\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("Supervisor started in background (PID: %d), output goes to %s\n", cmd.Process.Pid, logFile)
+}
+
+// stopSupervisor asks a running supervisor to shut down so it doesn't restart stopped services
+func stopSupervisor(all bool) {
+	pidFile := helpers.GetPIDFilePath("supervisor")
+	pids, _ := helpers.GetRunningPIDs(pidFile)
+	if len(pids) == 0 {
+		return
+	}
+
+	if !all {
+		fmt.Printf("Warning: supervisor (PID: %d) is running and will restart stopped services\n", pids[0])
+		return
+	}
+
+	// The supervisor stops its children before exiting
+	fmt.Printf("Stopping supervisor (PID: %d)...\n", pids[0])
+	if err := helpers.StopPIDFile(pidFile, supervisor.DefaultOptions().StopTimeout*2); err != nil {
+		fmt.Printf("Warning: Failed to stop supervisor: %v\n", err)
+	}
+}
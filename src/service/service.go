@@ -0,0 +1,227 @@
+package service
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+
+	"github.com/alexivashchenko/go-dev-server/helpers"
+	"github.com/alexivashchenko/go-dev-server/launcher"
+	"github.com/alexivashchenko/go-dev-server/status"
+)
+
+// Service is a server component that can be started, stopped, and restarted
+type Service interface {
+	Name() string
+	DependsOn() []string
+	Start() error
+	Stop() error
+	Restart() error
+	Status() status.Status
+}
+
+// Supervised is implemented by services that run a long-lived process usable in run mode
+type Supervised interface {
+	Service
+	// Process prepares the service and returns the process to run in the foreground
+	Process() (launcher.Spec, error)
+	// Ready waits until the service accepts requests
+	Ready() error
+}
+
+// Logged is implemented by services writing log files besides their captured output
+type Logged interface {
+	Service
+	LogFiles() []string
+}
+
+// Reloadable is implemented by services that can apply configuration changes without a restart
+type Reloadable interface {
+	Service
+	Reload() error
+}
+
+var (
+	registryMu sync.Mutex
+	registry   []Service
+)
+
+// Register adds a service to the registry, names must be unique
+func Register(s Service) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	for _, registered := range registry {
+		if strings.EqualFold(registered.Name(), s.Name()) {
+			panic(fmt.Sprintf("service %s is already registered", s.Name()))
+		}
+	}
+
+	registry = append(registry, s)
+}
+
+// All returns every registered service in registration order
+func All() []Service {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	return append([]Service(nil), registry...)
+}
+
+// Enabled returns the registered services not disabled with <NAME>_ENABLED in .env
+func Enabled() []Service {
+	var enabled []Service
+	for _, s := range All() {
+		if IsEnabled(s) {
+			enabled = append(enabled, s)
+		}
+	}
+
+	return enabled
+}
+
+// IsEnabled reports whether a service is enabled, services are enabled unless <NAME>_ENABLED is false
+func IsEnabled(s Service) bool {
+	return helpers.GetEnvBool(EnvPrefix(s)+"_ENABLED", true)
+}
+
+// EnvPrefix returns the prefix used for the environment variables of a service
+func EnvPrefix(s Service) string {
+	return strings.ToUpper(s.Name())
+}
+
+// Names returns the lower-case names of services
+func Names(services []Service) []string {
+	names := make([]string, 0, len(services))
+	for _, s := range services {
+		names = append(names, strings.ToLower(s.Name()))
+	}
+
+	return names
+}
+
+// Select returns the enabled services matching the given names, or all enabled services when no names are given
+func Select(names []string, withDependencies bool) ([]Service, error) {
+	all := All()
+	byName := make(map[string]Service, len(all))
+	for _, s := range all {
+		byName[strings.ToLower(s.Name())] = s
+	}
+
+	// Catch typos in dependency declarations even if they are not selected
+	for _, s := range all {
+		for _, dependency := range s.DependsOn() {
+			if _, ok := byName[strings.ToLower(dependency)]; !ok {
+				return nil, fmt.Errorf("service %s depends on unknown service %s", s.Name(), dependency)
+			}
+		}
+	}
+
+	enabled := Enabled()
+	if len(names) == 0 {
+		return enabled, nil
+	}
+
+	wanted := make(map[string]bool)
+	var queue []string
+	for _, name := range names {
+		key := strings.ToLower(name)
+		s, ok := byName[key]
+		if !ok {
+			return nil, fmt.Errorf("unknown service: %s (available: %s)", name, strings.Join(Names(enabled), ", "))
+		}
+		if !IsEnabled(s) {
+			return nil, fmt.Errorf("service %s is disabled with %s_ENABLED", s.Name(), EnvPrefix(s))
+		}
+		wanted[key] = true
+		queue = append(queue, key)
+	}
+
+	// Pull in dependencies transitively, disabled ones are expected to be provided elsewhere
+	for withDependencies && len(queue) > 0 {
+		key := queue[0]
+		queue = queue[1:]
+		for _, dependency := range byName[key].DependsOn() {
+			dependencyKey := strings.ToLower(dependency)
+			if wanted[dependencyKey] {
+				continue
+			}
+			if !IsEnabled(byName[dependencyKey]) {
+				log.Printf("Warning: %s depends on disabled service %s", byName[key].Name(), dependency)
+				continue
+			}
+			wanted[dependencyKey] = true
+			queue = append(queue, dependencyKey)
+		}
+	}
+
+	// Keep the registration order
+	var selected []Service
+	for _, s := range enabled {
+		if wanted[strings.ToLower(s.Name())] {
+			selected = append(selected, s)
+		}
+	}
+
+	return selected, nil
+}
+
+// Waves groups services into waves where every service only depends on services of earlier waves
+func Waves(services []Service) ([][]Service, error) {
+	byName := make(map[string]Service, len(services))
+	for _, s := range services {
+		byName[strings.ToLower(s.Name())] = s
+	}
+
+	// Count unresolved dependencies of every service, ignoring services that are not selected
+	pending := make(map[string]int, len(services))
+	dependants := make(map[string][]string)
+	for _, s := range services {
+		name := strings.ToLower(s.Name())
+		pending[name] = 0
+		for _, dependency := range s.DependsOn() {
+			dependency = strings.ToLower(dependency)
+			if _, ok := byName[dependency]; !ok {
+				continue
+			}
+			dependants[dependency] = append(dependants[dependency], name)
+			pending[name]++
+		}
+	}
+
+	var waves [][]Service
+	resolved := 0
+	for resolved < len(services) {
+		// Keep the registration order inside a wave
+		var wave []Service
+		for _, s := range services {
+			if count, ok := pending[strings.ToLower(s.Name())]; ok && count == 0 {
+				wave = append(wave, s)
+			}
+		}
+
+		if len(wave) == 0 {
+			var cycle []string
+			for _, s := range services {
+				if _, ok := pending[strings.ToLower(s.Name())]; ok {
+					cycle = append(cycle, s.Name())
+				}
+			}
+			return nil, fmt.Errorf("dependency cycle detected between services: %s", strings.Join(cycle, ", "))
+		}
+
+		for _, s := range wave {
+			name := strings.ToLower(s.Name())
+			delete(pending, name)
+			for _, dependant := range dependants[name] {
+				pending[dependant]--
+			}
+		}
+
+		waves = append(waves, wave)
+		resolved += len(wave)
+	}
+
+	return waves, nil
+}
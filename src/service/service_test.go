@@ -0,0 +1,219 @@
+package service
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/alexivashchenko/go-dev-server/status"
+)
+
+// fakeService is a service that only has a name and dependencies
+type fakeService struct {
+	name      string
+	dependsOn []string
+}
+
+func (s fakeService) Name() string          { return s.name }
+func (s fakeService) DependsOn() []string   { return s.dependsOn }
+func (s fakeService) Start() error          { return nil }
+func (s fakeService) Stop() error           { return nil }
+func (s fakeService) Restart() error        { return nil }
+func (s fakeService) Status() status.Status { return status.Status{} }
+
+// waveNames returns the service names of every wave
+func waveNames(waves [][]Service) [][]string {
+	names := make([][]string, 0, len(waves))
+	for _, wave := range waves {
+		names = append(names, Names(wave))
+	}
+
+	return names
+}
+
+// useRegistry replaces the registry for the duration of a test
+func useRegistry(t *testing.T, services ...Service) {
+	t.Helper()
+
+	registryMu.Lock()
+	previous := registry
+	registry = services
+	registryMu.Unlock()
+
+	t.Cleanup(func() {
+		registryMu.Lock()
+		registry = previous
+		registryMu.Unlock()
+	})
+}
+
+func TestWaves(t *testing.T) {
+	tests := []struct {
+		name     string
+		services []Service
+		want     [][]string
+		wantErr  string
+	}{
+		{
+			name: "dependencies start first",
+			services: []Service{
+				fakeService{name: "mysql"},
+				fakeService{name: "ssl"},
+				fakeService{name: "php", dependsOn: []string{"mailpit"}},
+				fakeService{name: "nginx", dependsOn: []string{"ssl", "php"}},
+				fakeService{name: "mailpit"},
+			},
+			want: [][]string{{"mysql", "ssl", "mailpit"}, {"php"}, {"nginx"}},
+		},
+		{
+			name: "unselected dependencies are ignored",
+			services: []Service{
+				fakeService{name: "nginx", dependsOn: []string{"SSL", "php"}},
+				fakeService{name: "php"},
+			},
+			want: [][]string{{"php"}, {"nginx"}},
+		},
+		{
+			name: "cycle",
+			services: []Service{
+				fakeService{name: "mysql"},
+				fakeService{name: "a", dependsOn: []string{"b"}},
+				fakeService{name: "b", dependsOn: []string{"a"}},
+			},
+			wantErr: "dependency cycle detected between services: a, b",
+		},
+		{
+			name: "self dependency",
+			services: []Service{
+				fakeService{name: "a", dependsOn: []string{"a"}},
+			},
+			wantErr: "dependency cycle detected between services: a",
+		},
+		{
+			name: "none",
+			want: nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			waves, err := Waves(tt.services)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("Waves() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got := waveNames(waves)
+			if !slices.EqualFunc(got, tt.want, slices.Equal) {
+				t.Errorf("Waves() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSelect(t *testing.T) {
+	useRegistry(t,
+		fakeService{name: "MySQL"},
+		fakeService{name: "SSL"},
+		fakeService{name: "PHP", dependsOn: []string{"Mailpit"}},
+		fakeService{name: "Nginx", dependsOn: []string{"SSL", "PHP"}},
+		fakeService{name: "Mailpit"},
+	)
+
+	tests := []struct {
+		name             string
+		names            []string
+		withDependencies bool
+		disabled         string
+		want             []string
+		wantErr          string
+	}{
+		{name: "all", want: []string{"mysql", "ssl", "php", "nginx", "mailpit"}},
+		{name: "without dependencies", names: []string{"nginx"}, want: []string{"nginx"}},
+		{name: "with dependencies", names: []string{"NGINX"}, withDependencies: true, want: []string{"ssl", "php", "nginx", "mailpit"}},
+		{name: "disabled dependency", names: []string{"nginx"}, withDependencies: true, disabled: "MAILPIT", want: []string{"ssl", "php", "nginx"}},
+		{name: "disabled service", names: []string{"mailpit"}, disabled: "MAILPIT", wantErr: "service Mailpit is disabled with MAILPIT_ENABLED"},
+		{name: "unknown service", names: []string{"redis"}, wantErr: "unknown service: redis"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, name := range []string{"MYSQL", "SSL", "PHP", "NGINX", "MAILPIT"} {
+				value := "true"
+				if name == tt.disabled {
+					value = "false"
+				}
+				t.Setenv(name+"_ENABLED", value)
+			}
+
+			selected, err := Select(tt.names, tt.withDependencies)
+			if tt.wantErr != "" {
+				if err == nil || !strings.HasPrefix(err.Error(), tt.wantErr) {
+					t.Fatalf("Select() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got := Names(selected); !slices.Equal(got, tt.want) {
+				t.Errorf("Select() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSelectUnknownDependency(t *testing.T) {
+	useRegistry(t,
+		fakeService{name: "nginx", dependsOn: []string{"phpp"}},
+	)
+
+	if _, err := Select(nil, false); err == nil || err.Error() != "service nginx depends on unknown service phpp" {
+		t.Errorf("Select() error = %v, want the unknown dependency", err)
+	}
+}
+
+func TestIsEnabled(t *testing.T) {
+	tests := []struct {
+		value string
+		want  bool
+	}{
+		{value: "", want: true},
+		{value: "true", want: true},
+		{value: "1", want: true},
+		{value: "false", want: false},
+		{value: " No ", want: false},
+		{value: "OFF", want: false},
+		{value: "0", want: false},
+		{value: "yes", want: true},
+		// A typo falls back to the default with a warning
+		{value: "flase", want: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			t.Setenv("MAILPIT_ENABLED", tt.value)
+
+			if got := IsEnabled(fakeService{name: "Mailpit"}); got != tt.want {
+				t.Errorf("IsEnabled() with MAILPIT_ENABLED=%q = %t, want %t", tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRegisterDuplicateName(t *testing.T) {
+	useRegistry(t, fakeService{name: "PHP"})
+
+	defer func() {
+		if recover() == nil {
+			t.Error("Register() did not panic on a duplicate name")
+		}
+	}()
+	Register(fakeService{name: "php"})
+}
@@ -0,0 +1,125 @@
+package sites
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// phpIniPrefix marks keys of the site configuration overriding php.ini for the site, PHP_INI_memory_limit='1G'
+const phpIniPrefix = "PHP_INI_"
+
+// iniDirectives lists the php.ini directives a site may override, directives PHP only reads at startup are left out
+var iniDirectives = []string{
+	"allow_url_fopen",
+	"allow_url_include",
+	"arg_separator.input",
+	"arg_separator.output",
+	"assert.active",
+	"auto_append_file",
+	"auto_prepend_file",
+	"curl.cainfo",
+	"date.timezone",
+	"default_charset",
+	"default_mimetype",
+	"default_socket_timeout",
+	"display_errors",
+	"display_startup_errors",
+	"error_log",
+	"error_reporting",
+	"file_uploads",
+	"html_errors",
+	"ignore_user_abort",
+	"implicit_flush",
+	"include_path",
+	"intl.default_locale",
+	"log_errors",
+	"mail.add_x_header",
+	"mail.log",
+	"max_execution_time",
+	"max_file_uploads",
+	"max_input_nesting_level",
+	"max_input_time",
+	"max_input_vars",
+	"mbstring.language",
+	"memory_limit",
+	"mysqli.default_socket",
+	"open_basedir",
+	"opcache.enable",
+	"opcache.revalidate_freq",
+	"opcache.validate_timestamps",
+	"openssl.cafile",
+	"output_buffering",
+	"output_handler",
+	"pcre.backtrack_limit",
+	"pcre.jit",
+	"pcre.recursion_limit",
+	"pdo_mysql.default_socket",
+	"post_max_size",
+	"precision",
+	"register_argc_argv",
+	"request_order",
+	"sendmail_from",
+	"sendmail_path",
+	"serialize_precision",
+	"session.auto_start",
+	"session.cookie_domain",
+	"session.cookie_httponly",
+	"session.cookie_lifetime",
+	"session.cookie_path",
+	"session.cookie_samesite",
+	"session.cookie_secure",
+	"session.gc_divisor",
+	"session.gc_maxlifetime",
+	"session.gc_probability",
+	"session.name",
+	"session.save_handler",
+	"session.save_path",
+	"session.use_strict_mode",
+	"short_open_tag",
+	"SMTP",
+	"smtp_port",
+	"upload_max_filesize",
+	"upload_tmp_dir",
+	"user_agent",
+	"variables_order",
+	"xdebug.client_host",
+	"xdebug.client_port",
+	"xdebug.idekey",
+	"xdebug.log",
+	"xdebug.max_nesting_level",
+	"xdebug.output_dir",
+	"xdebug.start_with_request",
+	"xdebug.var_display_max_children",
+	"xdebug.var_display_max_data",
+	"xdebug.var_display_max_depth",
+	"zend.assertions",
+	"zend.exception_ignore_args",
+}
+
+// setIniValue validates a php.ini override of the site configuration and stores it in values
+func setIniValue(values map[string]string, key, directive, value string) error {
+	if !slices.Contains(iniDirectives, directive) {
+		return fmt.Errorf("%s: unknown php.ini directive %q", key, directive)
+	}
+	if strings.ContainsAny(value, "\"\r\n") {
+		return fmt.Errorf("%s: quotes and line breaks are not supported", key)
+	}
+	// A quoted php.ini value reads backslashes as escapes
+	if strings.Contains(value, ";") && strings.Contains(value, `\`) {
+		return fmt.Errorf("%s: use forward slashes in values containing semicolons", key)
+	}
+
+	values[directive] = value
+
+	return nil
+}
+
+// IniValue returns a value as written into php.ini, quoted when a semicolon would start a comment
+func IniValue(value string) string {
+	if strings.Contains(value, ";") {
+		return `"` + value + `"`
+	}
+
+	return value
+}
@@ -0,0 +1,52 @@
+package sites
+
+import "testing"
+
+func TestSetIniValue(t *testing.T) {
+	tests := []struct {
+		name      string
+		directive string
+		value     string
+		wantErr   bool
+	}{
+		{name: "known directive", directive: "memory_limit", value: "1G"},
+		{name: "dotted directive", directive: "session.gc_maxlifetime", value: "1440"},
+		{name: "constants", directive: "error_reporting", value: "E_ALL & ~E_DEPRECATED"},
+		{name: "semicolon with forward slashes", directive: "include_path", value: ".;C:/php/pear"},
+		{name: "typo", directive: "memory_limt", value: "1G", wantErr: true},
+		{name: "read at startup", directive: "disable_functions", value: "exec", wantErr: true},
+		{name: "quote", directive: "user_agent", value: `say "hi"`, wantErr: true},
+		{name: "line break", directive: "user_agent", value: "a\nb", wantErr: true},
+		{name: "semicolon with backslashes", directive: "include_path", value: `.;C:\php\pear`, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			values := map[string]string{}
+			err := setIniValue(values, phpIniPrefix+tt.directive, tt.directive, tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("setIniValue() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && values[tt.directive] != tt.value {
+				t.Errorf("values[%s] = %q, want %q", tt.directive, values[tt.directive], tt.value)
+			}
+		})
+	}
+}
+
+func TestIniValue(t *testing.T) {
+	tests := []struct {
+		value string
+		want  string
+	}{
+		{value: "1G", want: "1G"},
+		{value: "E_ALL & ~E_DEPRECATED", want: "E_ALL & ~E_DEPRECATED"},
+		{value: ".;C:/php/pear", want: `".;C:/php/pear"`},
+	}
+
+	for _, tt := range tests {
+		if got := IniValue(tt.value); got != tt.want {
+			t.Errorf("IniValue(%q) = %q, want %q", tt.value, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,114 @@
+package sites
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/alexivashchenko/go-dev-server/helpers"
+)
+
+// namePattern matches site names usable as the first label of a domain
+var namePattern = regexp.MustCompile(`^[a-z0-9]([a-z0-9-]*[a-z0-9])?$`)
+
+// LinksFile returns the registry of sites linked from outside the www folder
+func LinksFile() string {
+	return filepath.Join(helpers.GetRootDirectory(), "etc", "links.json")
+}
+
+// LoadLinks returns the linked sites by name
+func LoadLinks() (map[string]string, error) {
+	links := map[string]string{}
+
+	content, err := os.ReadFile(LinksFile())
+	if os.IsNotExist(err) {
+		return links, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", LinksFile(), err)
+	}
+
+	if err := json.Unmarshal(content, &links); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", LinksFile(), err)
+	}
+
+	return links, nil
+}
+
+// saveLinks writes the registry
+func saveLinks(links map[string]string) error {
+	content, err := json.MarshalIndent(links, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(LinksFile()), 0755); err != nil {
+		return fmt.Errorf("failed to create %s: %w", filepath.Dir(LinksFile()), err)
+	}
+
+	return helpers.WriteFileAtomic(LinksFile(), append(content, '\n'))
+}
+
+// Link registers a folder as a site, the name defaults to the folder name
+func Link(path, name string) (Site, error) {
+	config, err := NewConfiguration()
+	if err != nil {
+		return Site{}, err
+	}
+
+	dir, err := filepath.Abs(path)
+	if err != nil {
+		return Site{}, fmt.Errorf("failed to resolve %s: %w", path, err)
+	}
+	if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+		return Site{}, fmt.Errorf("%s is not a directory", dir)
+	}
+
+	if name == "" {
+		name = filepath.Base(dir)
+	}
+	name = strings.ToLower(name)
+	if !namePattern.MatchString(name) {
+		return Site{}, fmt.Errorf("invalid site name %q, use letters, digits and dashes", name)
+	}
+
+	if existing, ok := config.Links[name]; ok && existing != dir {
+		return Site{}, fmt.Errorf("site %s is already linked to %s", name, existing)
+	}
+
+	site, err := load(dir, name, config.DomainTail)
+	if err != nil {
+		return Site{}, err
+	}
+
+	// Listing all sites catches domains already served by a parked folder or a proxy
+	config.Links[name] = dir
+	if _, err := List(config); err != nil {
+		return Site{}, err
+	}
+
+	if err := saveLinks(config.Links); err != nil {
+		return Site{}, err
+	}
+
+	return site, nil
+}
+
+// Unlink removes a site from the registry, the folder itself is left alone
+func Unlink(name string) error {
+	links, err := LoadLinks()
+	if err != nil {
+		return err
+	}
+
+	name = strings.ToLower(name)
+	if _, ok := links[name]; !ok {
+		return fmt.Errorf("no site linked as %s", name)
+	}
+
+	delete(links, name)
+	return saveLinks(links)
+}
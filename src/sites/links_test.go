@@ -0,0 +1,168 @@
+package sites
+
+import (
+	"maps"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/alexivashchenko/go-dev-server/helpers"
+)
+
+// setupLinks points the server root at an empty temporary folder with a www/ directory and clients/ parked
+func setupLinks(t *testing.T) string {
+	t.Helper()
+
+	root := t.TempDir()
+	if err := helpers.SetRootDirectory(root); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("NGINX_DOMAIN_TAIL", "test")
+	t.Setenv("PARKED_DIRECTORIES", "{ROOT_DIR}/clients")
+	if err := os.MkdirAll(filepath.Join(root, "www"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	return root
+}
+
+func TestLink(t *testing.T) {
+	tests := []struct {
+		name string
+		// links already in etc/links.json, parked are site folders relative to the root
+		links   map[string]string
+		parked  []string
+		path    string
+		link    string
+		want    string
+		wantErr string
+	}{
+		{name: "name from the folder", path: "Shop-Front", want: "shop-front"},
+		{name: "explicit name", path: "shop", link: "store", want: "store"},
+		{name: "invalid name", path: "shop", link: "my_shop", wantErr: "invalid site name"},
+		{name: "invalid folder name", path: "my shop", wantErr: "invalid site name"},
+		{name: "missing folder", path: "missing", wantErr: "is not a directory"},
+		{name: "same path again", links: map[string]string{"shop": "shop"}, path: "shop", want: "shop"},
+		{name: "name linked to another path", links: map[string]string{"shop": "other"}, path: "shop", wantErr: "already linked to"},
+		{name: "domain of a www site", parked: []string{"www/shop"}, path: "shop", wantErr: "domain shop.test is used by both"},
+		{name: "domain of a parked site", parked: []string{"clients/shop"}, path: "shop", wantErr: "domain shop.test is used by both"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := setupLinks(t)
+			projects := filepath.Join(root, "projects")
+			for _, dir := range []string{"Shop-Front", "shop", "other", "my shop"} {
+				if err := os.MkdirAll(filepath.Join(projects, dir), 0755); err != nil {
+					t.Fatal(err)
+				}
+			}
+			for _, dir := range tt.parked {
+				if err := os.MkdirAll(filepath.Join(root, filepath.FromSlash(dir)), 0755); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if tt.links != nil {
+				links := map[string]string{}
+				for name, dir := range tt.links {
+					links[name] = filepath.Join(projects, dir)
+				}
+				if err := saveLinks(links); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			site, err := Link(filepath.Join(projects, tt.path), tt.link)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Link() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Link() error = %v", err)
+			}
+			if site.Name != tt.want || site.Domain != tt.want+".test" {
+				t.Errorf("Link() = %s (%s), want %s (%s.test)", site.Name, site.Domain, tt.want, tt.want)
+			}
+
+			links, err := LoadLinks()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := map[string]string{tt.want: filepath.Join(projects, tt.path)}; !maps.Equal(links, want) {
+				t.Errorf("LoadLinks() = %v, want %v", links, want)
+			}
+		})
+	}
+}
+
+func TestUnlink(t *testing.T) {
+	tests := []struct {
+		name    string
+		unlink  string
+		want    map[string]string
+		wantErr bool
+	}{
+		{name: "linked site", unlink: "shop", want: map[string]string{"blog": "/srv/blog"}},
+		{name: "name in another case", unlink: "Shop", want: map[string]string{"blog": "/srv/blog"}},
+		{name: "unknown site", unlink: "store", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setupLinks(t)
+			if err := saveLinks(map[string]string{"shop": "/srv/shop", "blog": "/srv/blog"}); err != nil {
+				t.Fatal(err)
+			}
+
+			err := Unlink(tt.unlink)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Unlink(%q) error = %v, wantErr %v", tt.unlink, err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			links, err := LoadLinks()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !maps.Equal(links, tt.want) {
+				t.Errorf("LoadLinks() = %v, want %v", links, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadLinks(t *testing.T) {
+	root := setupLinks(t)
+
+	links, err := LoadLinks()
+	if err != nil {
+		t.Fatalf("LoadLinks() without etc/links.json error = %v", err)
+	}
+	if len(links) != 0 {
+		t.Errorf("LoadLinks() without etc/links.json = %v, want none", links)
+	}
+
+	want := map[string]string{"shop": filepath.Join(root, "projects", "shop"), "blog": `D:\sites\blog`}
+	if err := saveLinks(want); err != nil {
+		t.Fatal(err)
+	}
+	links, err = LoadLinks()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !maps.Equal(links, want) {
+		t.Errorf("LoadLinks() = %v, want %v", links, want)
+	}
+
+	if err := os.WriteFile(LinksFile(), []byte("{"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadLinks(); err == nil {
+		t.Error("LoadLinks() with a broken etc/links.json should fail")
+	}
+}
@@ -0,0 +1,451 @@
+package sites
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/alexivashchenko/go-dev-server/env"
+	"github.com/alexivashchenko/go-dev-server/helpers"
+	"github.com/joho/godotenv"
+)
+
+// ConfigFileName is the optional per-site configuration file inside a site folder
+const ConfigFileName = ".devserver"
+
+// proxyEnvPrefix marks .env entries defining proxy sites without a folder, PROXY_API='8080' serves api.<tail>
+const proxyEnvPrefix = "PROXY_"
+
+// envPrefix marks keys of the site configuration passed to PHP as environment variables
+const envPrefix = "ENV_"
+
+// Configuration holds where sites are found and how their domains are built
+type Configuration struct {
+	// Parked are the folders whose sub-folders are sites, www/ comes first
+	Parked []Parked
+	// DomainTail is used by linked and proxy sites
+	DomainTail string
+	// Proxies maps names of proxy sites without a folder to their PROXY value
+	Proxies map[string]string
+	// Links maps names of sites outside the www folder to their paths
+	Links map[string]string
+}
+
+// Parked is a folder whose sub-folders are served as <folder>.<DomainTail>
+type Parked struct {
+	Dir        string
+	DomainTail string
+}
+
+// Site is a website folder together with its per-site settings
+type Site struct {
+	Name string
+	// Dir is the absolute path of the site folder, empty for proxy sites defined in .env
+	Dir    string
+	Domain string
+	// Aliases are extra domains served by the site
+	Aliases []string
+	// DocRoot is the document root relative to Dir, empty for the folder itself
+	DocRoot string
+	// Driver names the kind of application, empty to detect it
+	Driver     string
+	PHPVersion string
+	// HTTPSOnly redirects plain HTTP requests to HTTPS
+	HTTPSOnly bool
+	// Snippet is the absolute path of an Nginx snippet included into the server block
+	Snippet string
+	// Proxy is the host:port requests are forwarded to instead of serving files
+	Proxy string
+	// Env holds environment variables passed to PHP
+	Env map[string]string
+	// PHPIni maps php.ini directives to the values overriding php.ini for the site
+	PHPIni  map[string]string
+	Enabled bool
+}
+
+// NewConfiguration creates a new sites configuration
+func NewConfiguration() (*Configuration, error) {
+	domainTail := os.Getenv("NGINX_DOMAIN_TAIL")
+	if domainTail == "" {
+		return nil, fmt.Errorf("NGINX_DOMAIN_TAIL environment variable is not set")
+	}
+
+	config := &Configuration{
+		Parked:     []Parked{{Dir: filepath.Join(helpers.GetRootDirectory(), "www"), DomainTail: domainTail}},
+		DomainTail: domainTail,
+		Proxies:    map[string]string{},
+	}
+
+	if err := config.parseParked(os.Getenv("PARKED_DIRECTORIES")); err != nil {
+		return nil, fmt.Errorf("invalid PARKED_DIRECTORIES in .env: %w", err)
+	}
+
+	links, err := LoadLinks()
+	if err != nil {
+		return nil, err
+	}
+	config.Links = links
+
+	// Only .env defines proxy sites, system variables such as PROXY_HOST are not meant for the server
+	values, err := env.Read()
+	if err != nil {
+		return nil, err
+	}
+	for key, value := range values {
+		if name, ok := strings.CutPrefix(key, proxyEnvPrefix); ok && name != "" {
+			config.Proxies[strings.ReplaceAll(strings.ToLower(name), "_", "-")] = value
+		}
+	}
+
+	return config, nil
+}
+
+// parseParked adds the folders of a PARKED_DIRECTORIES value such as "D:\clients=client;{ROOT_DIR}\tools"
+func (c *Configuration) parseParked(value string) error {
+	for _, entry := range strings.Split(value, ";") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		// Windows paths contain colons, so the tail is separated by the last equals sign
+		dir, tail := entry, c.DomainTail
+		if index := strings.LastIndex(entry, "="); index >= 0 {
+			dir, tail = strings.TrimSpace(entry[:index]), strings.Trim(strings.TrimSpace(entry[index+1:]), ".")
+		}
+		if tail == "" || !hostnamePattern.MatchString(tail) {
+			return fmt.Errorf("invalid domain tail %q for %s", tail, dir)
+		}
+
+		dir = strings.ReplaceAll(dir, "{ROOT_DIR}", helpers.GetRootDirectory())
+		dir, err := filepath.Abs(dir)
+		if err != nil {
+			return fmt.Errorf("failed to resolve %s: %w", dir, err)
+		}
+
+		// Listing www/ again only changes its tail
+		replaced := false
+		for i := range c.Parked {
+			if c.Parked[i].Dir == dir {
+				c.Parked[i].DomainTail = tail
+				replaced = true
+			}
+		}
+		if !replaced {
+			c.Parked = append(c.Parked, Parked{Dir: dir, DomainTail: tail})
+		}
+	}
+
+	return nil
+}
+
+// DomainTails returns every domain tail in use, the default one first
+func (c *Configuration) DomainTails() []string {
+	tails := []string{c.DomainTail}
+	for _, parked := range c.Parked {
+		if !slices.Contains(tails, parked.DomainTail) {
+			tails = append(tails, parked.DomainTail)
+		}
+	}
+
+	return tails
+}
+
+// All returns the enabled sites of the configured www folder
+func All() ([]Site, error) {
+	config, err := NewConfiguration()
+	if err != nil {
+		return nil, err
+	}
+
+	return List(config)
+}
+
+// List returns the enabled sites, a site with an invalid configuration file is an error
+func List(config *Configuration) ([]Site, error) {
+	var sites []Site
+	for i, parked := range config.Parked {
+		dirs, err := helpers.ListDirectories(parked.Dir)
+		if err != nil {
+			// Only www/ is required, other parked folders may live on a drive that isn't mounted
+			if i > 0 && errors.Is(err, os.ErrNotExist) {
+				log.Printf("Warning: Parked directory %s does not exist", parked.Dir)
+				continue
+			}
+			return nil, fmt.Errorf("failed to list website directories: %w", err)
+		}
+
+		for _, dir := range dirs {
+			site, err := Load(filepath.Join(parked.Dir, dir), parked.DomainTail)
+			if err != nil {
+				return nil, err
+			}
+			if !site.Enabled {
+				log.Printf("Site %s is disabled in %s", site.Name, ConfigFileName)
+				continue
+			}
+			sites = append(sites, site)
+		}
+	}
+
+	for _, name := range sortedKeys(config.Links) {
+		dir := config.Links[name]
+		if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+			log.Printf("Warning: Linked site %s points to missing folder %s", name, dir)
+			continue
+		}
+
+		site, err := load(dir, name, config.DomainTail)
+		if err != nil {
+			return nil, err
+		}
+		if !site.Enabled {
+			log.Printf("Site %s is disabled in %s", site.Name, ConfigFileName)
+			continue
+		}
+		sites = append(sites, site)
+	}
+
+	for _, name := range sortedKeys(config.Proxies) {
+		proxy, err := parseProxy(config.Proxies[name])
+		if err != nil {
+			log.Printf("Warning: Skipping invalid %s%s in .env: %v", proxyEnvPrefix, strings.ToUpper(strings.ReplaceAll(name, "-", "_")), err)
+			continue
+		}
+		sites = append(sites, Site{
+			Name:    name,
+			Domain:  name + "." + config.DomainTail,
+			Proxy:   proxy,
+			Env:     map[string]string{},
+			Enabled: true,
+		})
+	}
+
+	// Two sites answering the same domain would make Nginx pick one at random
+	owners := make(map[string]string)
+	for _, site := range sites {
+		for _, domain := range site.Domains() {
+			if owner, ok := owners[domain]; ok {
+				return nil, fmt.Errorf("domain %s is used by both %s and %s", domain, owner, site.Source())
+			}
+			owners[domain] = site.Source()
+		}
+	}
+
+	return sites, nil
+}
+
+// Folders returns the folders of parked and linked sites without reading their configuration files
+func Folders(config *Configuration) []string {
+	var folders []string
+	for _, parked := range config.Parked {
+		dirs, _ := helpers.ListDirectories(parked.Dir)
+		for _, dir := range dirs {
+			folders = append(folders, filepath.Join(parked.Dir, dir))
+		}
+	}
+
+	for _, name := range sortedKeys(config.Links) {
+		if info, err := os.Stat(config.Links[name]); err == nil && info.IsDir() {
+			folders = append(folders, config.Links[name])
+		}
+	}
+
+	return folders
+}
+
+// Root returns the absolute document root of the site, empty for proxy sites defined in .env
+func (s Site) Root() string {
+	if s.Dir == "" {
+		return ""
+	}
+	return filepath.Join(s.Dir, filepath.FromSlash(s.DocRoot))
+}
+
+// Source describes where a site comes from, for messages
+func (s Site) Source() string {
+	if s.Dir == "" {
+		return proxyEnvPrefix + strings.ToUpper(strings.ReplaceAll(s.Name, "-", "_")) + " in .env"
+	}
+	return s.Dir
+}
+
+// Domains returns the main domain followed by the aliases
+func (s Site) Domains() []string {
+	return append([]string{s.Domain}, s.Aliases...)
+}
+
+// hostnamePattern matches the domains accepted as aliases
+var hostnamePattern = regexp.MustCompile(`^(\*\.)?[a-zA-Z0-9]([a-zA-Z0-9-]*[a-zA-Z0-9])?(\.[a-zA-Z0-9]([a-zA-Z0-9-]*[a-zA-Z0-9])?)*$`)
+
+// envNamePattern matches the environment variable names accepted for PHP
+var envNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// Load reads a site folder and its optional configuration file, the folder name becomes the site name
+func Load(dir, domainTail string) (Site, error) {
+	return load(dir, filepath.Base(dir), domainTail)
+}
+
+// load reads a site folder served under the given name
+func load(dir, name, domainTail string) (Site, error) {
+	site := Site{
+		Name:    name,
+		Dir:     dir,
+		Domain:  name + "." + domainTail,
+		Env:     map[string]string{},
+		PHPIni:  map[string]string{},
+		Enabled: true,
+	}
+
+	configFile := filepath.Join(dir, ConfigFileName)
+	if _, err := os.Stat(configFile); os.IsNotExist(err) {
+		return site, nil
+	}
+
+	values, err := godotenv.Read(configFile)
+	if err != nil {
+		return site, fmt.Errorf("failed to read %s: %w", configFile, err)
+	}
+
+	if err := site.apply(values); err != nil {
+		return site, fmt.Errorf("invalid %s: %w", configFile, err)
+	}
+
+	return site, nil
+}
+
+// apply copies the values of a site configuration file into the site
+func (s *Site) apply(values map[string]string) error {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		value := strings.TrimSpace(values[key])
+
+		switch {
+		case key == "ENABLED":
+			enabled, err := helpers.ParseBool(value)
+			if err != nil {
+				return fmt.Errorf("%s: %w", key, err)
+			}
+			s.Enabled = enabled
+
+		case key == "DOCROOT":
+			docRoot := strings.Trim(helpers.ReplaceBackslashToSlash(value), "/")
+			if docRoot == ".." || strings.HasPrefix(docRoot, "../") || strings.Contains(docRoot, "/../") {
+				return fmt.Errorf("%s must stay inside the site folder: %s", key, value)
+			}
+			s.DocRoot = docRoot
+
+		case key == "ALIASES":
+			for _, alias := range strings.FieldsFunc(value, isListSeparator) {
+				if !hostnamePattern.MatchString(alias) {
+					return fmt.Errorf("%s: invalid domain %q", key, alias)
+				}
+				s.Aliases = append(s.Aliases, strings.ToLower(alias))
+			}
+
+		case key == "DRIVER":
+			s.Driver = strings.ToLower(value)
+
+		case key == "PROXY":
+			proxy, err := parseProxy(value)
+			if err != nil {
+				return fmt.Errorf("%s: %w", key, err)
+			}
+			s.Proxy = proxy
+
+		case key == "PHP_VERSION":
+			s.PHPVersion = value
+
+		case key == "HTTPS_ONLY":
+			httpsOnly, err := helpers.ParseBool(value)
+			if err != nil {
+				return fmt.Errorf("%s: %w", key, err)
+			}
+			s.HTTPSOnly = httpsOnly
+
+		case key == "NGINX_SNIPPET":
+			if value == "" {
+				continue
+			}
+			snippet := value
+			if !filepath.IsAbs(snippet) {
+				snippet = filepath.Join(s.Dir, filepath.FromSlash(snippet))
+			}
+			if _, err := os.Stat(snippet); err != nil {
+				return fmt.Errorf("%s: %w", key, err)
+			}
+			s.Snippet = snippet
+
+		case strings.HasPrefix(key, envPrefix):
+			name := strings.TrimPrefix(key, envPrefix)
+			if !envNamePattern.MatchString(name) {
+				return fmt.Errorf("invalid environment variable name %q", name)
+			}
+			// Values end up in a quoted Nginx string
+			if strings.ContainsAny(value, "\"\\\r\n") {
+				return fmt.Errorf("%s: quotes, backslashes and line breaks are not supported", key)
+			}
+			s.Env[name] = value
+
+		case strings.HasPrefix(key, phpIniPrefix):
+			if err := setIniValue(s.PHPIni, key, strings.TrimPrefix(key, phpIniPrefix), value); err != nil {
+				return err
+			}
+
+		default:
+			return fmt.Errorf("unknown setting %s", key)
+		}
+	}
+
+	return nil
+}
+
+// sortedKeys returns the keys of a map in alphabetical order
+func sortedKeys(values map[string]string) []string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
+
+// parseProxy accepts a port, host:port or http://host:port and returns host:port
+func parseProxy(value string) (string, error) {
+	address := strings.TrimSuffix(strings.TrimPrefix(strings.TrimSpace(value), "http://"), "/")
+	if !strings.Contains(address, ":") {
+		address = "127.0.0.1:" + address
+	}
+
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return "", fmt.Errorf("invalid proxy address %q", value)
+	}
+	if number, err := strconv.Atoi(port); err != nil || number < 1 || number > 65535 {
+		return "", fmt.Errorf("invalid proxy port in %q", value)
+	}
+	if host == "" {
+		host = "127.0.0.1"
+	}
+
+	return net.JoinHostPort(host, port), nil
+}
+
+// isListSeparator splits lists written with commas or spaces
+func isListSeparator(r rune) bool {
+	return r == ',' || r == ' ' || r == '\t'
+}
@@ -0,0 +1,226 @@
+package sites
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/alexivashchenko/go-dev-server/helpers"
+)
+
+func TestParseProxy(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    string
+		wantErr bool
+	}{
+		{value: "5173", want: "127.0.0.1:5173"},
+		{value: " 8080 ", want: "127.0.0.1:8080"},
+		{value: "localhost:3000", want: "localhost:3000"},
+		{value: "http://192.168.1.10:8000/", want: "192.168.1.10:8000"},
+		{value: ":9000", want: "127.0.0.1:9000"},
+		{value: "[::1]:8080", want: "[::1]:8080"},
+		{value: "proxy.corp", wantErr: true},
+		{value: "0", wantErr: true},
+		{value: "65536", wantErr: true},
+		{value: "localhost:http", wantErr: true},
+		{value: "https://localhost:8443", wantErr: true},
+		{value: "", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, err := parseProxy(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseProxy(%q) error = %v, wantErr %v", tt.value, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("parseProxy(%q) = %q, want %q", tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestApply(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "nginx.conf"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		values  map[string]string
+		check   func(t *testing.T, site Site)
+		wantErr bool
+	}{
+		{
+			name:   "document root",
+			values: map[string]string{"DOCROOT": `\public\`},
+			check: func(t *testing.T, site Site) {
+				if site.DocRoot != "public" {
+					t.Errorf("DocRoot = %q, want public", site.DocRoot)
+				}
+			},
+		},
+		{name: "document root outside the folder", values: map[string]string{"DOCROOT": "../other"}, wantErr: true},
+		{name: "nested document root outside the folder", values: map[string]string{"DOCROOT": "public/../../other"}, wantErr: true},
+		{
+			name:   "aliases",
+			values: map[string]string{"ALIASES": "Shop.local, *.admin.local  api.local"},
+			check: func(t *testing.T, site Site) {
+				if want := []string{"shop.local", "*.admin.local", "api.local"}; !slices.Equal(site.Aliases, want) {
+					t.Errorf("Aliases = %v, want %v", site.Aliases, want)
+				}
+			},
+		},
+		{name: "invalid alias", values: map[string]string{"ALIASES": "shop_local"}, wantErr: true},
+		{
+			name:   "flags",
+			values: map[string]string{"ENABLED": "no", "HTTPS_ONLY": "On"},
+			check: func(t *testing.T, site Site) {
+				if site.Enabled || !site.HTTPSOnly {
+					t.Errorf("Enabled = %t, HTTPSOnly = %t, want false and true", site.Enabled, site.HTTPSOnly)
+				}
+			},
+		},
+		{name: "invalid flag", values: map[string]string{"HTTPS_ONLY": "maybe"}, wantErr: true},
+		{
+			name:   "proxy",
+			values: map[string]string{"PROXY": "5173"},
+			check: func(t *testing.T, site Site) {
+				if site.Proxy != "127.0.0.1:5173" {
+					t.Errorf("Proxy = %q, want 127.0.0.1:5173", site.Proxy)
+				}
+			},
+		},
+		{name: "invalid proxy", values: map[string]string{"PROXY": "localhost:99999"}, wantErr: true},
+		{
+			name:   "relative snippet",
+			values: map[string]string{"NGINX_SNIPPET": "nginx.conf"},
+			check: func(t *testing.T, site Site) {
+				if want := filepath.Join(dir, "nginx.conf"); site.Snippet != want {
+					t.Errorf("Snippet = %q, want %q", site.Snippet, want)
+				}
+			},
+		},
+		{name: "missing snippet", values: map[string]string{"NGINX_SNIPPET": "missing.conf"}, wantErr: true},
+		{
+			name:   "environment",
+			values: map[string]string{"ENV_APP_ENV": "local"},
+			check: func(t *testing.T, site Site) {
+				if site.Env["APP_ENV"] != "local" {
+					t.Errorf("Env = %v, want APP_ENV=local", site.Env)
+				}
+			},
+		},
+		{name: "invalid environment name", values: map[string]string{"ENV_1APP": "local"}, wantErr: true},
+		{name: "quoted environment value", values: map[string]string{"ENV_APP_NAME": `"shop"`}, wantErr: true},
+		{
+			name:   "php.ini override",
+			values: map[string]string{"PHP_INI_memory_limit": "1G", "PHP_VERSION": "8.1"},
+			check: func(t *testing.T, site Site) {
+				if site.PHPIni["memory_limit"] != "1G" || site.PHPVersion != "8.1" {
+					t.Errorf("PHPIni = %v, PHPVersion = %q, want memory_limit=1G and 8.1", site.PHPIni, site.PHPVersion)
+				}
+			},
+		},
+		{name: "unknown setting", values: map[string]string{"DOC_ROOT": "public"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			site := Site{Name: "shop", Dir: dir, Env: map[string]string{}, PHPIni: map[string]string{}, Enabled: true}
+			err := site.apply(tt.values)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("apply() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.check != nil {
+				tt.check(t, site)
+			}
+		})
+	}
+}
+
+func TestParseParked(t *testing.T) {
+	root := t.TempDir()
+	if err := helpers.SetRootDirectory(root); err != nil {
+		t.Fatal(err)
+	}
+	www := filepath.Join(root, "www")
+	clients := filepath.Join(root, "clients")
+	tools := filepath.Join(root, "tools")
+
+	tests := []struct {
+		name    string
+		value   string
+		want    []Parked
+		wantErr bool
+	}{
+		{name: "empty", value: "", want: []Parked{{Dir: www, DomainTail: "test"}}},
+		{
+			name:  "own and default tail",
+			value: clients + "=client; {ROOT_DIR}/tools ;",
+			want:  []Parked{{Dir: www, DomainTail: "test"}, {Dir: clients, DomainTail: "client"}, {Dir: tools, DomainTail: "test"}},
+		},
+		{
+			name:  "dots around the tail",
+			value: clients + "=.client.",
+			want:  []Parked{{Dir: www, DomainTail: "test"}, {Dir: clients, DomainTail: "client"}},
+		},
+		{
+			name:  "www with another tail",
+			value: "{ROOT_DIR}/www=local",
+			want:  []Parked{{Dir: www, DomainTail: "local"}},
+		},
+		{name: "empty tail", value: clients + "=", wantErr: true},
+		{name: "invalid tail", value: clients + "=my_tail", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := &Configuration{Parked: []Parked{{Dir: www, DomainTail: "test"}}, DomainTail: "test"}
+			err := config.parseParked(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseParked() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !slices.Equal(config.Parked, tt.want) {
+				t.Errorf("Parked = %v, want %v", config.Parked, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewConfigurationProxies(t *testing.T) {
+	root := t.TempDir()
+	if err := helpers.SetRootDirectory(root); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("NGINX_DOMAIN_TAIL", "test")
+	// System variables are not proxy sites
+	t.Setenv("PROXY_HOST", "proxy.corp")
+	content := "PROXY_API='8080'\nPROXY_ADMIN_UI='localhost:5173'\nPROXY_BROKEN='proxy.corp'\n"
+	if err := os.WriteFile(filepath.Join(root, ".env"), []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Join(root, "www"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	config, err := NewConfiguration()
+	if err != nil {
+		t.Fatal(err)
+	}
+	siteList, err := List(config)
+	if err != nil {
+		t.Fatalf("List() error = %v, an invalid PROXY_ entry should be skipped", err)
+	}
+
+	var got []string
+	for _, site := range siteList {
+		got = append(got, site.Domain+"="+site.Proxy)
+	}
+	if want := []string{"admin-ui.test=localhost:5173", "api.test=127.0.0.1:8080"}; !slices.Equal(got, want) {
+		t.Errorf("proxy sites = %v, want %v", got, want)
+	}
+}
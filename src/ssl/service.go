@@ -0,0 +1,43 @@
+package ssl
+
+import (
+	"github.com/alexivashchenko/go-dev-server/status"
+)
+
+// Service exposes the SSL package to the service registry
+type Service struct{}
+
+// Name returns the service name used on the command line and in .env
+func (Service) Name() string {
+	return "SSL"
+}
+
+// DependsOn returns the services that must be started before SSL
+func (Service) DependsOn() []string {
+	return nil
+}
+
+// Start generates and installs the SSL certificate
+func (Service) Start() error {
+	return Start()
+}
+
+// Stop removes the SSL certificate from the system trust store
+func (Service) Stop() error {
+	return Stop()
+}
+
+// Restart reinstalls the SSL certificate
+func (Service) Restart() error {
+	return Restart()
+}
+
+// Status returns the current status of the SSL certificate
+func (Service) Status() status.Status {
+	return GetStatus()
+}
+
+// Reload regenerates the certificate when the site domains changed
+func (Service) Reload() error {
+	return Reload()
+}
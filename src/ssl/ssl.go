@@ -1,14 +1,26 @@
 package ssl
 
 import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
 	"fmt"
 	"log"
 	"os"
 	"path/filepath"
 	"runtime"
+	"slices"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/alexivashchenko/go-dev-server/helpers"
+	"github.com/alexivashchenko/go-dev-server/launcher"
+	"github.com/alexivashchenko/go-dev-server/sites"
+	"github.com/alexivashchenko/go-dev-server/status"
+	"github.com/alexivashchenko/go-dev-server/templates"
 )
 
 // Configuration holds all SSL-related paths and settings
@@ -22,8 +34,10 @@ type Configuration struct {
 	PrivateKeyFile            string
 	CSRFile                   string
 	CertificateFile           string
-	NginxDomainTail           string
-	ValidityDays              int
+	// InstalledDomainsFile holds the hash of the domains the installed certificate was generated for
+	InstalledDomainsFile string
+	NginxDomainTail      string
+	ValidityDays         int
 }
 
 // NewConfiguration creates a new SSL configuration
@@ -51,6 +65,7 @@ func NewConfiguration() (*Configuration, error) {
 		PrivateKeyFile:            filepath.Join(sslDir, "private.key"),
 		CSRFile:                   filepath.Join(sslDir, "csr.csr"),
 		CertificateFile:           filepath.Join(sslDir, "certificate.crt"),
+		InstalledDomainsFile:      filepath.Join(sslDir, "installed.sha256"),
 		NginxDomainTail:           nginxDomainTail,
 		ValidityDays:              365,
 	}, nil
@@ -66,20 +81,10 @@ func Start() error {
 		return fmt.Errorf("failed to initialize SSL configuration: %w", err)
 	}
 
-	// Create OpenSSL config file from template
-	if err := helpers.CopyFile(config.OpenSSLConfigTemplateFile, config.OpenSSLConfigFile); err != nil {
-		return fmt.Errorf("failed to copy OpenSSL config template: %w", err)
-	}
-
-	// Generate DNS entries for the certificate
-	dnsLines, err := generateDNSEntries(config)
+	// Render OpenSSL config with the DNS entries of all sites
+	domains, domainsChanged, err := renderOpenSSLConfig(config)
 	if err != nil {
-		return fmt.Errorf("failed to generate DNS entries: %w", err)
-	}
-
-	// Append DNS entries to OpenSSL config
-	if err := helpers.AppendLines(config.OpenSSLConfigFile, dnsLines); err != nil {
-		return fmt.Errorf("failed to append DNS entries to OpenSSL config: %w", err)
+		return err
 	}
 
 	// Check if certificate needs to be regenerated
@@ -88,6 +93,10 @@ func Start() error {
 		log.Printf("Warning: Could not determine if certificate needs regeneration: %v", err)
 		regenerate = true
 	}
+	if domainsChanged && !regenerate {
+		log.Println("Site domains changed since the certificate was generated")
+		regenerate = true
+	}
 
 	if regenerate {
 		log.Println("Generating new SSL certificate...")
@@ -103,6 +112,10 @@ func Start() error {
 		return fmt.Errorf("failed to install certificate: %w", err)
 	}
 
+	if err := recordInstalledDomains(config, domains); err != nil {
+		return err
+	}
+
 	elapsed := time.Since(startTime)
 	log.Printf("SSL configuration completed in %.2f seconds", elapsed.Seconds())
 	return nil
@@ -117,6 +130,11 @@ func Stop() error {
 		return fmt.Errorf("failed to uninstall certificate: %w", err)
 	}
 
+	// The next reload has to install the certificate again
+	if config, err := NewConfiguration(); err == nil {
+		helpers.RemoveFile(config.InstalledDomainsFile)
+	}
+
 	log.Println("SSL certificates removed successfully")
 	return nil
 }
@@ -136,8 +154,88 @@ func Restart() error {
 	return nil
 }
 
-// generateDNSEntries creates DNS entries for the certificate
-func generateDNSEntries(config *Configuration) ([]string, error) {
+// Reload regenerates and reinstalls the certificate only when the site domains changed
+func Reload() error {
+	config, err := NewConfiguration()
+	if err != nil {
+		return fmt.Errorf("failed to initialize SSL configuration: %w", err)
+	}
+
+	domains, domainsChanged, err := renderOpenSSLConfig(config)
+	if err != nil {
+		return err
+	}
+
+	regenerate, err := shouldRegenerateCertificate(config)
+	if err != nil {
+		return fmt.Errorf("failed to check SSL certificate: %w", err)
+	}
+
+	if !domainsChanged && !regenerate {
+		log.Println("Site domains unchanged, keeping the SSL certificate")
+		return nil
+	}
+
+	log.Println("Generating new SSL certificate...")
+	if err := createCertificate(config); err != nil {
+		return fmt.Errorf("failed to create certificate: %w", err)
+	}
+
+	if err := installCertificate(config); err != nil {
+		return fmt.Errorf("failed to install certificate: %w", err)
+	}
+
+	return recordInstalledDomains(config, domains)
+}
+
+// renderOpenSSLConfig renders the OpenSSL config and returns its domains and whether they differ from the ones of the installed certificate
+func renderOpenSSLConfig(config *Configuration) ([]string, bool, error) {
+	dnsLines, domains, err := generateDNSEntries(config)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to generate DNS entries: %w", err)
+	}
+
+	data := templates.NewData(config.RootDir)
+	data.SSL.AltNames = dnsLines
+	content, err := templates.Render(config.OpenSSLConfigTemplateFile, data)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to render OpenSSL config: %w", err)
+	}
+
+	// openssl reads the config from disk, so it is written even if generating the certificate fails later
+	if current, err := os.ReadFile(config.OpenSSLConfigFile); err != nil || !bytes.Equal(current, content) {
+		if err := helpers.WriteFileAtomic(config.OpenSSLConfigFile, content); err != nil {
+			return nil, false, fmt.Errorf("failed to write OpenSSL config: %w", err)
+		}
+	}
+
+	// The local IP changes with the network, only the domains decide whether the certificate is replaced
+	installed, err := os.ReadFile(config.InstalledDomainsFile)
+	changed := err != nil || strings.TrimSpace(string(installed)) != domainsHash(domains)
+
+	return domains, changed, nil
+}
+
+// recordInstalledDomains remembers the domains of a certificate that was generated and installed successfully
+func recordInstalledDomains(config *Configuration, domains []string) error {
+	if err := helpers.WriteFileAtomic(config.InstalledDomainsFile, []byte(domainsHash(domains)+"\n")); err != nil {
+		return fmt.Errorf("failed to write %s: %w", config.InstalledDomainsFile, err)
+	}
+
+	return nil
+}
+
+// domainsHash returns the hex SHA-256 of the sorted domains of a certificate
+func domainsHash(domains []string) string {
+	sorted := slices.Clone(domains)
+	slices.Sort(sorted)
+
+	sum := sha256.Sum256([]byte(strings.Join(sorted, "\n")))
+	return hex.EncodeToString(sum[:])
+}
+
+// generateDNSEntries creates the subject alternative names of the certificate and returns its domains
+func generateDNSEntries(config *Configuration) ([]string, []string, error) {
 	dnsLines := []string{
 		"IP.1 = 127.0.0.1",
 		"DNS.2 = localhost",
@@ -149,31 +247,47 @@ func generateDNSEntries(config *Configuration) ([]string, error) {
 		dnsLines = append(dnsLines, fmt.Sprintf("IP.2 = %s", localIP))
 	}
 
-	// Get website directories
-	dirs, err := helpers.ListDirectories(config.WWWDir)
+	siteConfig, err := sites.NewConfiguration()
 	if err != nil {
-		return nil, fmt.Errorf("failed to list website directories: %w", err)
+		return nil, nil, err
 	}
 
-	// Add DNS entries for each website directory
-	dnsIndex := 3
-	for _, dir := range dirs {
-		baseName := filepath.Base(dir)
-		domain := fmt.Sprintf("%s.%s", baseName, config.NginxDomainTail)
+	siteList, err := sites.List(siteConfig)
+	if err != nil {
+		return nil, nil, err
+	}
 
-		dnsLines = append(dnsLines, fmt.Sprintf("DNS.%d = %s", dnsIndex, domain))
+	// Add DNS entries for each website and its aliases
+	dnsIndex := 3
+	for _, site := range siteList {
+		dnsLines = append(dnsLines, fmt.Sprintf("DNS.%d = %s", dnsIndex, site.Domain))
 		dnsIndex++
 
-		dnsLines = append(dnsLines, fmt.Sprintf("DNS.%d = *.%s", dnsIndex, domain))
+		dnsLines = append(dnsLines, fmt.Sprintf("DNS.%d = *.%s", dnsIndex, site.Domain))
 		dnsIndex++
+
+		for _, alias := range site.Aliases {
+			dnsLines = append(dnsLines, fmt.Sprintf("DNS.%d = %s", dnsIndex, alias))
+			dnsIndex++
+		}
 	}
 
 	// Add wildcard entries
 	dnsLines = append(dnsLines, fmt.Sprintf("DNS.%d = *.localhost", dnsIndex))
 	dnsIndex++
-	dnsLines = append(dnsLines, fmt.Sprintf("DNS.%d = *.%s", dnsIndex, config.NginxDomainTail))
+	for _, tail := range siteConfig.DomainTails() {
+		dnsLines = append(dnsLines, fmt.Sprintf("DNS.%d = *.%s", dnsIndex, tail))
+		dnsIndex++
+	}
 
-	return dnsLines, nil
+	var domains []string
+	for _, line := range dnsLines {
+		if name, domain, ok := strings.Cut(line, " = "); ok && strings.HasPrefix(name, "DNS.") {
+			domains = append(domains, domain)
+		}
+	}
+
+	return dnsLines, domains, nil
 }
 
 // shouldRegenerateCertificate checks if the certificate needs to be regenerated
@@ -184,7 +298,6 @@ func shouldRegenerateCertificate(config *Configuration) (bool, error) {
 	}
 
 	// TODO: Check certificate expiration date
-	// TODO: Check if domains have changed since last generation
 
 	return false, nil
 }
@@ -192,22 +305,38 @@ func shouldRegenerateCertificate(config *Configuration) (bool, error) {
 // createCertificate generates a new SSL certificate
 func createCertificate(config *Configuration) error {
 	log.Println("Generating private key...")
-	cmd := fmt.Sprintf("openssl genrsa -out %s 2048", config.PrivateKeyFile)
-	if err := helpers.RunCommand(cmd, false); err != nil {
+	err := launcher.Run(launcher.Spec{
+		Executable: "openssl",
+		Args:       []string{"genrsa", "-out", config.PrivateKeyFile, "2048"},
+	})
+	if err != nil {
 		return fmt.Errorf("failed to generate private key: %w", err)
 	}
 
 	log.Println("Generating certificate signing request...")
-	cmd = fmt.Sprintf("openssl req -new -key %s -out %s -config %s",
-		config.PrivateKeyFile, config.CSRFile, config.OpenSSLConfigFile)
-	if err := helpers.RunCommand(cmd, false); err != nil {
+	err = launcher.Run(launcher.Spec{
+		Executable: "openssl",
+		Args: []string{"req", "-new",
+			"-key", config.PrivateKeyFile,
+			"-out", config.CSRFile,
+			"-config", config.OpenSSLConfigFile},
+	})
+	if err != nil {
 		return fmt.Errorf("failed to generate CSR: %w", err)
 	}
 
 	log.Println("Generating self-signed certificate...")
-	cmd = fmt.Sprintf("openssl x509 -req -days %d -in %s -signkey %s -out %s -extensions v3_req -extfile %s",
-		config.ValidityDays, config.CSRFile, config.PrivateKeyFile, config.CertificateFile, config.OpenSSLConfigFile)
-	if err := helpers.RunCommand(cmd, false); err != nil {
+	err = launcher.Run(launcher.Spec{
+		Executable: "openssl",
+		Args: []string{"x509", "-req",
+			"-days", strconv.Itoa(config.ValidityDays),
+			"-in", config.CSRFile,
+			"-signkey", config.PrivateKeyFile,
+			"-out", config.CertificateFile,
+			"-extensions", "v3_req",
+			"-extfile", config.OpenSSLConfigFile},
+	})
+	if err != nil {
 		return fmt.Errorf("failed to generate certificate: %w", err)
 	}
 
@@ -246,8 +375,8 @@ func uninstallCertificate() error {
 func installWindowsCertificate(certificateFile string) error {
 	log.Println("Installing certificate in Windows trust store...")
 	err := helpers.RunPowerShellAsAdmin(fmt.Sprintf(
-		"Import-Certificate -FilePath \"%s\" -CertStoreLocation Cert:\\LocalMachine\\Root",
-		certificateFile))
+		"Import-Certificate -FilePath %s -CertStoreLocation Cert:\\LocalMachine\\Root",
+		helpers.QuotePowerShell(certificateFile)))
 	if err != nil {
 		return fmt.Errorf("failed to install Windows certificate: %w", err)
 	}
@@ -269,8 +398,11 @@ func uninstallWindowsCertificate() error {
 func installMacCertificate(certificateFile string) error {
 	log.Println("Installing certificate in macOS trust store...")
 	// Add certificate to keychain
-	cmd := fmt.Sprintf("security add-trusted-cert -d -r trustRoot -k /Library/Keychains/System.keychain %s", certificateFile)
-	if err := helpers.RunCommand(cmd, true); err != nil {
+	err := launcher.Run(launcher.Spec{
+		Executable: "security",
+		Args:       []string{"add-trusted-cert", "-d", "-r", "trustRoot", "-k", "/Library/Keychains/System.keychain", certificateFile},
+	})
+	if err != nil {
 		return fmt.Errorf("failed to install macOS certificate: %w", err)
 	}
 	return nil
@@ -279,10 +411,27 @@ func installMacCertificate(certificateFile string) error {
 // macOS-specific certificate removal
 func uninstallMacCertificate() error {
 	log.Println("Removing certificate from macOS trust store...")
-	// Find and remove certificate from keychain
-	cmd := "security find-certificate -a -c local_server -Z | grep SHA-1 | awk '{print $NF}' | xargs -I {} security delete-certificate -Z {}"
-	if err := helpers.RunCommand(cmd, true); err != nil {
-		return fmt.Errorf("failed to remove macOS certificate: %w", err)
+	// Find the certificate hashes in the keychain
+	output, err := launcher.Output(launcher.Spec{
+		Executable: "security",
+		Args:       []string{"find-certificate", "-a", "-c", "local_server", "-Z"},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to find macOS certificate: %w", err)
+	}
+
+	for _, line := range strings.Split(output, "\n") {
+		if !strings.HasPrefix(strings.TrimSpace(line), "SHA-1") {
+			continue
+		}
+		fields := strings.Fields(line)
+		err := launcher.Run(launcher.Spec{
+			Executable: "security",
+			Args:       []string{"delete-certificate", "-Z", fields[len(fields)-1]},
+		})
+		if err != nil {
+			return fmt.Errorf("failed to remove macOS certificate: %w", err)
+		}
 	}
 	return nil
 }
@@ -299,7 +448,7 @@ func installLinuxCertificate(certificateFile string) error {
 			return fmt.Errorf("failed to copy certificate: %w", err)
 		}
 
-		if err := helpers.RunCommand("update-ca-certificates", true); err != nil {
+		if err := launcher.Run(launcher.Spec{Executable: "update-ca-certificates"}); err != nil {
 			return fmt.Errorf("failed to update CA certificates: %w", err)
 		}
 	} else if _, err := os.Stat("/etc/redhat-release"); err == nil {
@@ -309,7 +458,7 @@ func installLinuxCertificate(certificateFile string) error {
 			return fmt.Errorf("failed to copy certificate: %w", err)
 		}
 
-		if err := helpers.RunCommand("update-ca-trust extract", true); err != nil {
+		if err := launcher.Run(launcher.Spec{Executable: "update-ca-trust", Args: []string{"extract"}}); err != nil {
 			return fmt.Errorf("failed to update CA certificates: %w", err)
 		}
 	} else {
@@ -330,7 +479,7 @@ func uninstallLinuxCertificate() error {
 			return fmt.Errorf("failed to remove certificate: %w", err)
 		}
 
-		if err := helpers.RunCommand("update-ca-certificates --fresh", true); err != nil {
+		if err := launcher.Run(launcher.Spec{Executable: "update-ca-certificates", Args: []string{"--fresh"}}); err != nil {
 			return fmt.Errorf("failed to update CA certificates: %w", err)
 		}
 	} else if _, err := os.Stat("/etc/redhat-release"); err == nil {
@@ -339,7 +488,7 @@ func uninstallLinuxCertificate() error {
 			return fmt.Errorf("failed to remove certificate: %w", err)
 		}
 
-		if err := helpers.RunCommand("update-ca-trust extract", true); err != nil {
+		if err := launcher.Run(launcher.Spec{Executable: "update-ca-trust", Args: []string{"extract"}}); err != nil {
 			return fmt.Errorf("failed to update CA certificates: %w", err)
 		}
 	} else {
@@ -350,16 +499,50 @@ func uninstallLinuxCertificate() error {
 }
 
 // GetStatus returns the current status of the SSL configuration
-func GetStatus() string {
+func GetStatus() status.Status {
 	config, err := NewConfiguration()
 	if err != nil {
-		return "Error: " + err.Error()
+		return status.FromError(err)
 	}
 
 	if _, err := os.Stat(config.CertificateFile); os.IsNotExist(err) {
-		return "Not configured"
+		return status.Status{State: status.NotConfigured}
+	}
+
+	result := status.Status{State: status.Active}
+
+	notAfter, err := certificateExpiry(config)
+	if err != nil {
+		result.Health = status.Unhealthy + ": " + err.Error()
+		return result
+	}
+
+	result.Details = "expires " + notAfter.Format("2006-01-02")
+	if time.Now().After(notAfter) {
+		result.Health = status.Unhealthy + ": certificate expired"
+	} else {
+		result.Health = status.Healthy
+	}
+
+	return result
+}
+
+// certificateExpiry returns the expiration date of the generated certificate
+func certificateExpiry(config *Configuration) (time.Time, error) {
+	content, err := os.ReadFile(config.CertificateFile)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to read certificate: %w", err)
+	}
+
+	block, _ := pem.Decode(content)
+	if block == nil {
+		return time.Time{}, fmt.Errorf("certificate %s is not PEM encoded", config.CertificateFile)
+	}
+
+	certificate, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to parse certificate: %w", err)
 	}
 
-	// TODO: Check certificate expiration and return more detailed status
-	return "Active"
+	return certificate.NotAfter, nil
 }
@@ -0,0 +1,90 @@
+package ssl
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/alexivashchenko/go-dev-server/helpers"
+)
+
+// mkdir creates a folder for a test
+func mkdir(t *testing.T, dir string) {
+	t.Helper()
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRenderOpenSSLConfigReportsChangedDomains(t *testing.T) {
+	root := t.TempDir()
+	if err := helpers.SetRootDirectory(root); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("NGINX_DOMAIN_TAIL", "test")
+
+	template, err := os.ReadFile(filepath.Join("..", "..", "tpl", "ssl", "openssl.conf.tpl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	mkdir(t, filepath.Join(root, "tpl", "ssl"))
+	if err := os.WriteFile(filepath.Join(root, "tpl", "ssl", "openssl.conf.tpl"), template, 0644); err != nil {
+		t.Fatal(err)
+	}
+	mkdir(t, filepath.Join(root, "www", "blog"))
+
+	config, err := NewConfiguration()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	steps := []struct {
+		name        string
+		prepare     func()
+		wantChanged bool
+	}{
+		{name: "nothing installed", wantChanged: true},
+		{name: "same domains", wantChanged: false},
+		{
+			// The local IP is left out, a new network address doesn't replace the certificate
+			name: "other openssl.conf",
+			prepare: func() {
+				if err := os.WriteFile(config.OpenSSLConfigFile, []byte("IP.2 = 10.0.0.2\n"), 0644); err != nil {
+					t.Fatal(err)
+				}
+			},
+			wantChanged: false,
+		},
+		{name: "site added", prepare: func() { mkdir(t, filepath.Join(root, "www", "shop")) }, wantChanged: true},
+	}
+
+	for _, step := range steps {
+		if step.prepare != nil {
+			step.prepare()
+		}
+
+		domains, changed, err := renderOpenSSLConfig(config)
+		if err != nil {
+			t.Fatalf("%s: %v", step.name, err)
+		}
+		if changed != step.wantChanged {
+			t.Errorf("%s: changed = %t, want %t", step.name, changed, step.wantChanged)
+		}
+		if err := recordInstalledDomains(config, domains); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestDomainsHashIgnoresOrder(t *testing.T) {
+	a := domainsHash([]string{"localhost", "blog.test", "*.blog.test"})
+	b := domainsHash([]string{"*.blog.test", "localhost", "blog.test"})
+	if a != b {
+		t.Errorf("domainsHash() differs by order: %s and %s", a, b)
+	}
+
+	if c := domainsHash([]string{"localhost", "blog.test"}); c == a {
+		t.Error("domainsHash() is the same for other domains")
+	}
+}
@@ -0,0 +1,145 @@
+package status
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/alexivashchenko/go-dev-server/helpers"
+	"github.com/alexivashchenko/go-dev-server/launcher"
+)
+
+// State describes whether a service is up
+type State string
+
+const (
+	Running       State = "running"
+	Stopped       State = "stopped"
+	Active        State = "active"
+	NotConfigured State = "not configured"
+	Error         State = "error"
+)
+
+// Status is the machine-readable state of a service
+type Status struct {
+	Name          string   `json:"name"`
+	State         State    `json:"state"`
+	PIDs          []int    `json:"pids"`
+	Addresses     []string `json:"addresses"`
+	StartedAt     string   `json:"started_at,omitempty"`
+	UptimeSeconds int64    `json:"uptime_seconds"`
+	Version       string   `json:"version,omitempty"`
+	Health        string   `json:"health,omitempty"`
+	LastError     string   `json:"last_error,omitempty"`
+	Details       string   `json:"details,omitempty"`
+}
+
+// Health results
+const (
+	Healthy   = "ok"
+	Unhealthy = "failing"
+)
+
+// FromError returns the status of a service whose configuration could not be loaded
+func FromError(err error) Status {
+	return Status{
+		State:     Error,
+		LastError: err.Error(),
+	}
+}
+
+// FromPIDFile fills state, PIDs and uptime from a service PID file
+func FromPIDFile(filename string) Status {
+	result := Status{State: Stopped}
+
+	pids, err := helpers.GetRunningPIDs(filename)
+	if err != nil {
+		result.State = Error
+		result.LastError = err.Error()
+		return result
+	}
+	if len(pids) == 0 {
+		return result
+	}
+
+	result.State = Running
+	result.PIDs = pids
+
+	// The PID file is rewritten when stale PIDs are dropped, so the uptime comes from the oldest process
+	var startedAt time.Time
+	for _, pid := range pids {
+		if started, err := helpers.ProcessStartTime(pid); err == nil && (startedAt.IsZero() || started.Before(startedAt)) {
+			startedAt = started
+		}
+	}
+	if !startedAt.IsZero() {
+		result.StartedAt = startedAt.Format(time.RFC3339)
+		result.UptimeSeconds = int64(time.Since(startedAt).Seconds())
+	}
+
+	return result
+}
+
+// CheckHealth runs a single readiness check and records its result
+func (s *Status) CheckHealth(check func() error) {
+	if s.State != Running {
+		return
+	}
+
+	if err := check(); err != nil {
+		s.Health = Unhealthy + ": " + err.Error()
+		return
+	}
+	s.Health = Healthy
+}
+
+// Uptime returns the uptime as a duration
+func (s Status) Uptime() time.Duration {
+	return time.Duration(s.UptimeSeconds) * time.Second
+}
+
+// lastErrorFile returns the file holding the last start error of a service
+func lastErrorFile(service string) string {
+	pidFile := helpers.GetPIDFilePath(service)
+	return strings.TrimSuffix(pidFile, filepath.Ext(pidFile)) + ".error"
+}
+
+// RecordError stores the last start error of a service, a nil error clears it
+func RecordError(service string, err error) {
+	filename := lastErrorFile(service)
+	if err == nil {
+		helpers.RemoveFile(filename)
+		return
+	}
+
+	if mkErr := os.MkdirAll(filepath.Dir(filename), 0755); mkErr == nil {
+		entry := time.Now().Format(time.RFC3339) + " " + err.Error()
+		os.WriteFile(filename, []byte(entry), 0644)
+	}
+}
+
+// LastError returns the last recorded start error of a service
+func LastError(service string) string {
+	content, err := os.ReadFile(lastErrorFile(service))
+	if err != nil {
+		return ""
+	}
+
+	return strings.TrimSpace(string(content))
+}
+
+// Version runs a version command and returns the first line of its output
+func Version(executable string, args ...string) string {
+	if _, err := os.Stat(executable); err != nil {
+		return ""
+	}
+
+	// nginx prints its version to stderr
+	output, err := launcher.Output(launcher.Spec{Executable: executable, Args: args})
+	if err != nil {
+		return ""
+	}
+
+	return strings.TrimSpace(strings.SplitN(strings.TrimSpace(output), "\n", 2)[0])
+}
@@ -0,0 +1,38 @@
+package status
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/alexivashchenko/go-dev-server/helpers"
+)
+
+func TestFromPIDFileUptime(t *testing.T) {
+	startedAt, err := helpers.ProcessStartTime(os.Getpid())
+	if err != nil {
+		t.Skipf("process start time not available: %v", err)
+	}
+
+	filename := filepath.Join(t.TempDir(), "test.pid")
+	if err := helpers.WritePIDFile(filename, os.Getpid()); err != nil {
+		t.Fatal(err)
+	}
+	// Rewriting the PID file must not reset the uptime
+	later := time.Now().Add(time.Hour)
+	if err := os.Chtimes(filename, later, later); err != nil {
+		t.Fatal(err)
+	}
+
+	result := FromPIDFile(filename)
+	if result.State != Running {
+		t.Fatalf("State = %s, want %s", result.State, Running)
+	}
+	if want := startedAt.Format(time.RFC3339); result.StartedAt != want {
+		t.Errorf("StartedAt = %s, want %s", result.StartedAt, want)
+	}
+	if want := int64(time.Since(startedAt).Seconds()); result.UptimeSeconds < want-1 || result.UptimeSeconds > want+1 {
+		t.Errorf("UptimeSeconds = %d, want about %d", result.UptimeSeconds, want)
+	}
+}
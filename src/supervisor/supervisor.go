@@ -0,0 +1,323 @@
+package supervisor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"slices"
+	"time"
+
+	"github.com/alexivashchenko/go-dev-server/helpers"
+	"github.com/alexivashchenko/go-dev-server/launcher"
+	"github.com/alexivashchenko/go-dev-server/logs"
+	"github.com/alexivashchenko/go-dev-server/status"
+)
+
+// Process describes a long-running service process owned by the supervisor
+type Process struct {
+	Name    string
+	PIDFile string
+	// Command prepares the service and returns the command to run in the foreground
+	Command func() (launcher.Spec, error)
+	// Ready blocks until the started process accepts requests, nil skips the check
+	Ready func() error
+}
+
+// Options controls how crashed processes are restarted
+type Options struct {
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	MaxRestarts    int
+	RestartWindow  time.Duration
+	StableAfter    time.Duration
+	StopTimeout    time.Duration
+	// RespawnCleanExits restarts a process that was ready and exited with status 0 right away,
+	// without counting it as a crash
+	RespawnCleanExits bool
+}
+
+// DefaultOptions returns the default restart policy
+func DefaultOptions() Options {
+	return Options{
+		InitialBackoff: 500 * time.Millisecond,
+		MaxBackoff:     30 * time.Second,
+		MaxRestarts:    5,
+		RestartWindow:  time.Minute,
+		StableAfter:    30 * time.Second,
+		StopTimeout:    10 * time.Second,
+	}
+}
+
+// Supervisor starts processes, restarts them when they exit and stops them on shutdown
+type Supervisor struct {
+	processes []Process
+	options   Options
+}
+
+// New creates a supervisor for processes given in start order
+func New(processes []Process, options Options) *Supervisor {
+	return &Supervisor{
+		processes: processes,
+		options:   options,
+	}
+}
+
+// worker tracks the supervision goroutine of a single process
+type worker struct {
+	process Process
+	cancel  context.CancelFunc
+	started chan struct{}
+	exited  chan struct{}
+	err     error
+}
+
+// Run starts all processes in order and keeps them alive until ctx is cancelled
+func (s *Supervisor) Run(ctx context.Context) error {
+	workers := make([]*worker, 0, len(s.processes))
+	exits := make(chan struct{}, len(s.processes))
+
+	for _, process := range s.processes {
+		if ctx.Err() != nil {
+			break
+		}
+
+		processCtx, cancel := context.WithCancel(context.Background())
+		w := &worker{
+			process: process,
+			cancel:  cancel,
+			started: make(chan struct{}),
+			exited:  make(chan struct{}),
+		}
+		workers = append(workers, w)
+
+		go func() {
+			w.err = s.supervise(processCtx, w)
+			close(w.exited)
+			exits <- struct{}{}
+		}()
+
+		// Start processes one after another so dependencies come up first
+		select {
+		case <-w.started:
+		case <-ctx.Done():
+		}
+	}
+
+	// Wait for shutdown or for every process to give up
+	running := len(workers)
+	for running > 0 {
+		select {
+		case <-ctx.Done():
+			s.shutdown(workers)
+			return nil
+		case <-exits:
+			running--
+		}
+	}
+
+	return errors.New("all supervised services have stopped")
+}
+
+// shutdown stops processes in reverse start order
+func (s *Supervisor) shutdown(workers []*worker) {
+	log.Println("Shutting down supervised services...")
+
+	for i := len(workers) - 1; i >= 0; i-- {
+		workers[i].cancel()
+		<-workers[i].exited
+		if workers[i].err != nil {
+			log.Printf("Warning: %s: %v", workers[i].process.Name, workers[i].err)
+		}
+	}
+}
+
+// supervise runs a process and restarts it with exponential backoff until ctx is cancelled
+func (s *Supervisor) supervise(ctx context.Context, w *worker) error {
+	name := w.process.Name
+	backoff := s.options.InitialBackoff
+	var crashes []time.Time
+	startedOnce := false
+
+	defer func() {
+		if !startedOnce {
+			close(w.started)
+		}
+	}()
+
+	for {
+		startTime := time.Now()
+		ready := false
+		err := s.runOnce(ctx, w.process, func() {
+			ready = true
+			if !startedOnce {
+				startedOnce = true
+				close(w.started)
+			}
+		})
+
+		if ctx.Err() != nil {
+			return nil
+		}
+
+		if err == nil && ready && s.options.RespawnCleanExits {
+			log.Printf("%s exited, respawning it", name)
+			backoff = s.options.InitialBackoff
+			continue
+		}
+
+		if err == nil {
+			err = errors.New("exited unexpectedly")
+		}
+		log.Printf("%s exited: %v", name, err)
+		status.RecordError(name, err)
+
+		// A process that ran long enough is considered healthy again
+		if time.Since(startTime) >= s.options.StableAfter {
+			backoff = s.options.InitialBackoff
+			crashes = nil
+		}
+
+		// Only count crashes inside the restart window
+		now := time.Now()
+		crashes = append(crashes, now)
+		recent := crashes[:0]
+		for _, crash := range crashes {
+			if now.Sub(crash) <= s.options.RestartWindow {
+				recent = append(recent, crash)
+			}
+		}
+		crashes = recent
+
+		if len(crashes) > s.options.MaxRestarts {
+			log.Printf("%s crashed %d times within %s, giving up", name, len(crashes), s.options.RestartWindow)
+			return fmt.Errorf("crash loop detected after %d restarts", s.options.MaxRestarts)
+		}
+
+		log.Printf("Restarting %s in %s...", name, backoff)
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(backoff):
+		}
+
+		backoff *= 2
+		if backoff > s.options.MaxBackoff {
+			backoff = s.options.MaxBackoff
+		}
+	}
+}
+
+// runOnce starts the process and waits until it exits or ctx is cancelled
+func (s *Supervisor) runOnce(ctx context.Context, process Process, onStart func()) error {
+	spec, err := process.Command()
+	if err != nil {
+		return fmt.Errorf("failed to prepare %s: %w", process.Name, err)
+	}
+
+	// The supervisor outlives its processes, so captured output is rotated while they run
+	var output *logs.RotatingWriter
+	if spec.Stdout != "" {
+		output, err = logs.OpenRotating(spec.Stdout)
+		if err != nil {
+			return fmt.Errorf("failed to prepare %s: %w", process.Name, err)
+		}
+		defer output.Close()
+		spec.Stdout, spec.Stderr = "", ""
+	}
+
+	cmd, err := spec.Command()
+	if err != nil {
+		return fmt.Errorf("failed to prepare %s: %w", process.Name, err)
+	}
+
+	if output != nil {
+		cmd.Stdout = output
+		cmd.Stderr = output
+		// Don't wait forever for children still holding the output pipe
+		cmd.WaitDelay = s.options.StopTimeout
+	}
+	if cmd.Stdout == nil {
+		cmd.Stdout = os.Stdout
+	}
+	if cmd.Stderr == nil {
+		cmd.Stderr = os.Stderr
+	}
+
+	if err := launcher.Start(cmd); err != nil {
+		return fmt.Errorf("failed to start %s: %w", process.Name, err)
+	}
+
+	log.Printf("%s started with PID %d", process.Name, cmd.Process.Pid)
+	if process.PIDFile != "" {
+		if err := helpers.WritePIDFile(process.PIDFile, cmd.Process.Pid); err != nil {
+			log.Printf("Warning: %v", err)
+		}
+		// Leave the file alone when something else has recorded its process in it since
+		pid := cmd.Process.Pid
+		defer func() {
+			if pids, _ := helpers.ReadPIDFile(process.PIDFile); slices.Equal(pids, []int{pid}) {
+				helpers.RemoveFile(process.PIDFile)
+			}
+		}()
+	}
+
+	exited := make(chan error, 1)
+	go func() {
+		exited <- cmd.Wait()
+	}()
+
+	// Dependants are only started once the process is ready
+	if process.Ready != nil {
+		ready := make(chan error, 1)
+		go func() {
+			ready <- process.Ready()
+		}()
+
+		select {
+		case err := <-ready:
+			if err != nil {
+				stopProcess(process.Name, cmd, exited, s.options.StopTimeout)
+				return fmt.Errorf("readiness check failed: %w", err)
+			}
+			log.Printf("%s is ready", process.Name)
+			status.RecordError(process.Name, nil)
+		case err := <-exited:
+			return err
+		case <-ctx.Done():
+			return stopProcess(process.Name, cmd, exited, s.options.StopTimeout)
+		}
+	}
+	onStart()
+
+	select {
+	case err := <-exited:
+		return err
+	case <-ctx.Done():
+		return stopProcess(process.Name, cmd, exited, s.options.StopTimeout)
+	}
+}
+
+// stopProcess asks a process to terminate and kills it when it does not exit in time
+func stopProcess(name string, cmd *exec.Cmd, exited chan error, timeout time.Duration) error {
+	log.Printf("Stopping %s (PID %d)...", name, cmd.Process.Pid)
+
+	if err := helpers.TerminateProcess(cmd.Process); err != nil {
+		log.Printf("Warning: Failed to terminate %s: %v", name, err)
+	}
+
+	select {
+	case <-exited:
+		log.Printf("%s stopped", name)
+		return nil
+	case <-time.After(timeout):
+		log.Printf("%s did not stop within %s, killing it", name, timeout)
+		if err := cmd.Process.Kill(); err != nil {
+			return fmt.Errorf("failed to kill %s: %w", name, err)
+		}
+		<-exited
+		return nil
+	}
+}
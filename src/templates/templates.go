@@ -0,0 +1,164 @@
+package templates
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+
+	"github.com/alexivashchenko/go-dev-server/helpers"
+)
+
+// Data is the model shared by all configuration templates
+type Data struct {
+	// RootFolder is the server root with forward slashes and a trailing slash
+	RootFolder string
+	SSL        SSL
+	Sites      []Site
+	// Site is the site being rendered by per-site templates
+	Site      Site
+	Upstreams []Upstream
+	MySQL     MySQL
+	PHP       PHP
+	Mailpit   Mailpit
+}
+
+// SSL describes the generated certificate
+type SSL struct {
+	Enabled     bool
+	Certificate string
+	Key         string
+	AltNames    []string
+}
+
+// Site describes a website served by Nginx
+type Site struct {
+	Name    string
+	Domain  string
+	Aliases []string
+	// Root is the document root with forward slashes and a trailing slash
+	Root string
+	// Driver selects the location blocks of the application, see the nginx package
+	Driver string
+	// Proxy is the host:port requests are forwarded to by the proxy driver
+	Proxy     string
+	HTTPSOnly bool
+	// Snippet is an Nginx file included into the server block, with forward slashes
+	Snippet string
+	// Env holds environment variables passed to PHP
+	Env map[string]string
+	// Upstream is the Nginx upstream of the PHP version the site runs on
+	Upstream string
+}
+
+// Upstream is a named group of backend servers
+type Upstream struct {
+	Name    string
+	Servers []string
+}
+
+// MySQL holds the values used by my.ini
+type MySQL struct {
+	DataFolder string
+	Port       int
+}
+
+// PHP holds the values used by php.ini
+type PHP struct {
+	AppFolder       string
+	ErrorLog        string
+	IncludePath     string
+	ExtensionDir    string
+	SessionSavePath string
+	CurlCaInfo      string
+	SendmailPath    string
+	// Extensions and ZendExtensions are loaded by name or by file name relative to ExtensionDir
+	Extensions     []string
+	ZendExtensions []string
+	Xdebug         Xdebug
+	// Sections are the per-site [HOST=...] and [PATH=...] sections, PHP-CGI applies them per request
+	Sections []INISection
+}
+
+// INISection is a php.ini section such as HOST=site.test with the directives it overrides
+type INISection struct {
+	Name   string
+	Values map[string]string
+}
+
+// Xdebug holds the values of the [xdebug] section of php.ini
+type Xdebug struct {
+	Enabled          bool
+	Mode             string
+	ClientHost       string
+	ClientPort       int
+	StartWithRequest string
+}
+
+// Mailpit holds the SMTP settings PHP sends mail to
+type Mailpit struct {
+	SMTPHost string
+	SMTPPort string
+}
+
+// NewData returns the data every template can rely on for the given root directory
+func NewData(rootDir string) Data {
+	rootFolder := helpers.ReplaceBackslashToSlash(rootDir)
+	if !strings.HasSuffix(rootFolder, "/") {
+		rootFolder += "/"
+	}
+
+	data := Data{
+		RootFolder: rootFolder,
+		SSL: SSL{
+			Certificate: rootFolder + "etc/ssl/certificate.crt",
+			Key:         rootFolder + "etc/ssl/private.key",
+		},
+	}
+
+	// SSL blocks are only emitted once a certificate has been generated
+	_, certErr := os.Stat(filepath.Join(rootDir, "etc", "ssl", "certificate.crt"))
+	_, keyErr := os.Stat(filepath.Join(rootDir, "etc", "ssl", "private.key"))
+	data.SSL.Enabled = certErr == nil && keyErr == nil
+
+	return data
+}
+
+// Render executes a template file with data and returns the result
+func Render(templateFile string, data Data) ([]byte, error) {
+	content, err := os.ReadFile(templateFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read template %s: %w", templateFile, err)
+	}
+
+	// Unknown fields and map keys fail instead of rendering empty values
+	tmpl, err := template.New(filepath.Base(templateFile)).
+		Option("missingkey=error").
+		Parse(string(content))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse template %s: %w", templateFile, err)
+	}
+
+	var output bytes.Buffer
+	if err := tmpl.Execute(&output, data); err != nil {
+		return nil, fmt.Errorf("failed to render template %s: %w", templateFile, err)
+	}
+
+	return output.Bytes(), nil
+}
+
+// RenderToFile renders a template file and atomically replaces the output file
+func RenderToFile(templateFile, outputFile string, data Data) error {
+	content, err := Render(templateFile, data)
+	if err != nil {
+		return err
+	}
+
+	if err := helpers.WriteFileAtomic(outputFile, content); err != nil {
+		return fmt.Errorf("failed to write %s: %w", outputFile, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"slices"
+	"syscall"
+	"time"
+
+	"github.com/alexivashchenko/go-dev-server/helpers"
+	"github.com/alexivashchenko/go-dev-server/service"
+	"github.com/alexivashchenko/go-dev-server/sites"
+)
+
+// watchSites polls the site folders and reloads the services once a change has settled
+func watchSites(services []service.Service) {
+	waves, err := service.Waves(services)
+	if err != nil {
+		fmt.Printf("Error: %s\n", err)
+		os.Exit(1)
+	}
+
+	// Polling works the same on every platform and network drive, site folders change rarely
+	interval := helpers.GetEnvDuration("WATCH_INTERVAL", time.Second)
+	debounce := helpers.GetEnvDuration("WATCH_DEBOUNCE", 2*time.Second)
+
+	applied, err := siteFolders()
+	if err != nil {
+		fmt.Printf("Error: %s\n", err)
+		os.Exit(1)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	fmt.Printf("Watching %d site folders, press Ctrl+C to stop...\n", len(applied))
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	latest, changedAt := applied, time.Time{}
+	for {
+		select {
+		case <-ctx.Done():
+			fmt.Println("Stopped watching")
+			return
+		case <-ticker.C:
+		}
+
+		current, err := siteFolders()
+		if err != nil {
+			fmt.Printf("Warning: %s\n", err)
+			continue
+		}
+
+		// Copying or extracting a project takes a while, wait until the folders stop changing
+		if !slices.Equal(current, latest) {
+			latest, changedAt = current, time.Now()
+			continue
+		}
+		if slices.Equal(latest, applied) || time.Since(changedAt) < debounce {
+			continue
+		}
+
+		printFolderChanges(applied, latest)
+		applied = latest
+
+		// A failed reload keeps the running configuration, the next change retries
+		for _, err := range reloadWaves(waves) {
+			fmt.Printf("Warning: %v\n", err)
+		}
+	}
+}
+
+// siteFolders returns the current folders of parked and linked sites
+func siteFolders() ([]string, error) {
+	config, err := sites.NewConfiguration()
+	if err != nil {
+		return nil, err
+	}
+
+	return sites.Folders(config), nil
+}
+
+// printFolderChanges prints the added, removed and renamed site folders
+func printFolderChanges(previous, current []string) {
+	var added, removed []string
+	for _, folder := range current {
+		if !slices.Contains(previous, folder) {
+			added = append(added, folder)
+		}
+	}
+	for _, folder := range previous {
+		if !slices.Contains(current, folder) {
+			removed = append(removed, folder)
+		}
+	}
+
+	// A single folder disappearing and appearing next to it is a rename
+	if len(added) == 1 && len(removed) == 1 && filepath.Dir(added[0]) == filepath.Dir(removed[0]) {
+		fmt.Printf("Site folder renamed: %s -> %s\n", removed[0], filepath.Base(added[0]))
+		return
+	}
+
+	for _, folder := range added {
+		fmt.Printf("Site folder added: %s\n", folder)
+	}
+	for _, folder := range removed {
+		fmt.Printf("Site folder removed: %s\n", folder)
+	}
+}
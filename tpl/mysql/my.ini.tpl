@@ -1,11 +1,11 @@
 [client]
 #password=your_password
-port=3306
+port={{.MySQL.Port}}
 socket=/tmp/mysql.sock
 
 [mysqld]
-datadir="C:/server/data/{mysql_data_folder}"
-port=3306
+datadir="{{.RootFolder}}data/{{.MySQL.DataFolder}}"
+port={{.MySQL.Port}}
 socket=/tmp/mysql.sock
 key_buffer_size=256M
 max_allowed_packet=512M
@@ -1,8 +1,10 @@
 server {
     listen 80 default_server;
+{{- if .SSL.Enabled}}
     listen 443 ssl default_server;
+{{- end}}
     server_name localhost;
-    root "{root_folder}www/";
+    root "{{.RootFolder}}www/";
 
     index index.html index.htm index.php;
 
@@ -10,7 +12,7 @@ server {
     allow       127.0.0.1;
     deny        all;
 
-    include "{root_folder}etc/nginx/alias/*.conf";
+    include "{{.RootFolder}}etc/nginx/alias/*.conf";
 
     location / {
         try_files $uri $uri/ =404;
@@ -23,12 +25,14 @@ server {
         #fastcgi_pass unix:/run/php/php7.0-fpm.sock;
     }
 
-    ssl_certificate "{root_folder}etc/ssl/certificate.crt";
-    ssl_certificate_key "{root_folder}etc/ssl/private.key";
+{{- if .SSL.Enabled}}
+    ssl_certificate "{{.SSL.Certificate}}";
+    ssl_certificate_key "{{.SSL.Key}}";
     ssl_session_timeout 5m;
     ssl_protocols TLSv1 TLSv1.1 TLSv1.2;
     ssl_ciphers ALL:!ADH:!EXPORT56:RC4+RSA:+HIGH:+MEDIUM:+LOW:+SSLv3:+EXP;
     ssl_prefer_server_ciphers on;
+{{- end}}
 
 
     charset utf-8;
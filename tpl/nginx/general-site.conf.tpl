@@ -1,39 +1,130 @@
+{{- $httpsOnly := and .Site.HTTPSOnly .SSL.Enabled}}
+{{- if $httpsOnly -}}
 server {
     listen 80;
+    server_name {{.Site.Domain}} *.{{.Site.Domain}}{{range .Site.Aliases}} {{.}}{{end}};
+    return 301 https://$host$request_uri;
+}
+
+{{end -}}
+server {
+{{- if not $httpsOnly}}
+    listen 80;
+{{- end}}
+{{- if .SSL.Enabled}}
     listen 443 ssl;
-    server_name {domain_name} *.{domain_name};
-    root "{root_folder}www/{folder_name}/";
+{{- end}}
+    server_name {{.Site.Domain}} *.{{.Site.Domain}}{{range .Site.Aliases}} {{.}}{{end}};
+{{- if .Site.Root}}
+    root "{{.Site.Root}}";
+{{- end}}
 
-	error_log {root_folder}logs/nginx/error-{domain_name}.log;
-	access_log {root_folder}logs/nginx/access-{domain_name}.log;
+	error_log {{.RootFolder}}logs/nginx/error-{{.Site.Domain}}.log;
+	access_log {{.RootFolder}}logs/nginx/access-{{.Site.Domain}}.log;
 
+{{- if eq .Site.Driver "proxy"}}
+    location / {
+        proxy_pass http://{{.Site.Proxy}};
+        proxy_http_version 1.1;
+        proxy_set_header Host $host;
+        proxy_set_header X-Real-IP $remote_addr;
+        proxy_set_header X-Forwarded-For $proxy_add_x_forwarded_for;
+        proxy_set_header X-Forwarded-Proto $scheme;
+        # WebSocket upgrades for hot module replacement
+        proxy_set_header Upgrade $http_upgrade;
+        proxy_set_header Connection $connection_upgrade;
+        proxy_read_timeout 1h;
+    }
+{{- else if eq .Site.Driver "spa"}}
+    index index.html;
+
+    location / {
+        try_files $uri $uri/ /index.html;
+    }
+{{- else}}
     index index.html index.htm index.php;
+{{- if eq .Site.Driver "laravel"}}
+
+    location / {
+        try_files $uri $uri/ /index.php?$query_string;
+    }
+{{- else if eq .Site.Driver "symfony"}}
+
+    location / {
+        try_files $uri /index.php$is_args$args;
+    }
+{{- else if eq .Site.Driver "craft"}}
+
+    location / {
+        try_files $uri/index.html $uri $uri/ /index.php?$query_string;
+    }
+{{- else if eq .Site.Driver "drupal"}}
+
+    location / {
+        try_files $uri /index.php?$query_string;
+    }
+    location ~ ^/sites/.*/private/ {
+        return 403;
+    }
+    location ~ \..*/.*\.php$ {
+        return 403;
+    }
+{{- else if eq .Site.Driver "wordpress" "wordpress-multisite"}}
+{{- if eq .Site.Driver "wordpress-multisite"}}
+
+    if (!-e $request_filename) {
+        rewrite /wp-admin$ $scheme://$host$uri/ permanent;
+        rewrite ^(/[^/]+)?(/wp-.*) $2 last;
+        rewrite ^(/[^/]+)?(/.*\.php) $2 last;
+    }
+{{- end}}
+
+    location / {
+        try_files $uri $uri/ /index.php?$args;
+    }
+{{- else}}
 
     location / {
         try_files $uri $uri/ /index.php$is_args$args;
 		autoindex on;
     }
+{{- end}}
 
     location ~ \.php$ {
         include snippets/fastcgi-php.conf;
-        fastcgi_pass php_upstream;
+        fastcgi_pass {{.Site.Upstream}};
         #fastcgi_pass unix:/run/php/php7.0-fpm.sock;
+{{- range $name, $value := .Site.Env}}
+        fastcgi_param {{$name}} "{{$value}}";
+{{- end}}
     }
+{{- end}}
 
-    ssl_certificate "{root_folder}etc/ssl/certificate.crt";
-    ssl_certificate_key "{root_folder}etc/ssl/private.key";
+{{- if .SSL.Enabled}}
+    ssl_certificate "{{.SSL.Certificate}}";
+    ssl_certificate_key "{{.SSL.Key}}";
     ssl_session_timeout 5m;
     ssl_protocols TLSv1 TLSv1.1 TLSv1.2;
     ssl_ciphers ALL:!ADH:!EXPORT56:RC4+RSA:+HIGH:+MEDIUM:+LOW:+SSLv3:+EXP;
     ssl_prefer_server_ciphers on;
+{{- end}}
 
 
     charset utf-8;
+{{- if ne .Site.Driver "proxy"}}
 
     location = /favicon.ico { access_log off; log_not_found off; }
     location = /robots.txt  { access_log off; log_not_found off; }
+{{- end}}
     location ~ /\.ht {
         deny all;
     }
+    location ~ /\.devserver {
+        deny all;
+    }
+{{- if .Site.Snippet}}
+
+    include "{{.Site.Snippet}}";
+{{- end}}
 }
 
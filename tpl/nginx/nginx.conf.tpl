@@ -2,11 +2,11 @@
 #user  nobody;
 worker_processes  1;
 
-error_log {root_folder}logs/nginx/error.log;
-#error_log {root_folder}logs/nginx/error.log  notice;
-#error_log {root_folder}logs/nginx/error.log  info;
+error_log {{.RootFolder}}logs/nginx/error.log;
+#error_log {{.RootFolder}}logs/nginx/error.log  notice;
+#error_log {{.RootFolder}}logs/nginx/error.log  info;
 
-pid        {root_folder}logs/nginx/nginx.pid;
+pid        {{.RootFolder}}logs/nginx/nginx.pid;
 
 
 events {
@@ -22,8 +22,13 @@ http {
     #                  '$status $body_bytes_sent "$http_referer" '
     #                  '"$http_user_agent" "$http_x_forwarded_for"';
 
-	access_log {root_folder}logs/nginx/access.log;
+	access_log {{.RootFolder}}logs/nginx/access.log;
 
+    # Proxy sites pass WebSocket upgrades through
+    map $http_upgrade $connection_upgrade {
+        default upgrade;
+        ''      close;
+    }
 
     sendfile        on;
     #tcp_nopush     on;
@@ -33,8 +38,15 @@ http {
 
     #gzip  on;
 
-    include "{root_folder}etc/nginx/php_upstream.conf";
-    include "{root_folder}etc/nginx/sites-enabled/*.conf";
+{{- range .Upstreams}}
+    upstream {{.Name}} {
+    {{- range .Servers}}
+        server {{.}} weight=1 max_fails=1 fail_timeout=1;
+    {{- end}}
+    }
+{{- end}}
+
+    include "{{.RootFolder}}etc/nginx/sites-enabled/*.conf";
     client_max_body_size 2000M;
 	server_names_hash_bucket_size 64;
 }
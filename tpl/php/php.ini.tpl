@@ -512,7 +512,7 @@ report_memleaks=On
 ; https://php.net/error-log
 ; Example:
 ;error_log = php_errors.log
-error_log={PHP_ERROR_LOG}
+error_log={{.PHP.ErrorLog}}
 ; Log errors to syslog (Event Log on Windows).
 ;error_log = syslog
 ; The syslog ident is a string which is prepended to every message logged
@@ -644,7 +644,7 @@ default_charset="UTF-8"
 ;
 ; Windows: "\path1;\path2"
 ;include_path = ".;c:\php\includes"
-include_path={PHP_INCLUDE_PATH}
+include_path={{.PHP.IncludePath}}
 ;
 ; PHP's default setting for include_path is ".;/path/to/php/pear"
 ; https://php.net/include-path
@@ -664,7 +664,7 @@ user_dir=
 ;extension_dir = "./"
 ; On windows:
 ;extension_dir = "ext"
-extension_dir={PHP_EXTENSION_DIR}
+extension_dir={{.PHP.ExtensionDir}}
 ; Directory where the temporary files should be placed.
 ; Defaults to the system default (see sys_get_temp_dir)
 ;sys_temp_dir = "/tmp"
@@ -791,46 +791,14 @@ default_socket_timeout=60
 ;   extension folders as well as the separate PECL DLL download.
 ;   Be sure to appropriately set the extension_dir directive.
 ;
-;extension=bz2
-; The ldap extension must be before curl if OpenSSL 1.0.2 and OpenLDAP is used
-; otherwise it results in segfault when unloading after using SASL.
-; See https://github.com/php/php-src/issues/8620 for more info.
-;extension=ldap
-extension=curl
-;extension=ffi
-;extension=ftp
-extension=fileinfo
-extension=gd
-;extension=gettext
-;extension=gmp
-extension=intl
-;extension=imap
-extension=mbstring
-extension=exif      ; Must be after mbstring as it depends on it
-extension=mysqli
-;extension=oci8_12c  ; Use with Oracle Database 12c Instant Client
-;extension=oci8_19  ; Use with Oracle Database 19 Instant Client
-;extension=odbc
-extension=openssl
-;extension=pdo_firebird
-extension=pdo_mysql
-;extension=pdo_oci
-;extension=pdo_odbc
-extension=pdo_pgsql
-extension=pdo_sqlite
-extension=pgsql
-;extension=shmop
-; The MIBS data available in the PHP distribution must be installed.
-; See https://www.php.net/manual/en/snmp.installation.php
-;extension=snmp
-;extension=soap
-extension=sockets
-;extension=sodium
-extension=sqlite3
-;extension=tidy
-extension=xsl
-extension=zip
-;zend_extension=opcache
+; The extensions below are managed with "server php ext enable|disable <name>"
+; and stored in PHP_EXTENSIONS in .env, their order is kept (exif needs mbstring first).
+{{- range .PHP.Extensions}}
+extension={{.}}
+{{- end}}
+{{- range .PHP.ZendExtensions}}
+zend_extension={{.}}
+{{- end}}
 ;;;;;;;;;;;;;;;;;;;
 ; Module Settings ;
 ;;;;;;;;;;;;;;;;;;;
@@ -936,9 +904,9 @@ pdo_mysql.default_socket=
 [mail function]
 ; For Win32 only.
 ; https://php.net/smtp
-SMTP={MAILPIT_SMTP_HOST}
+SMTP={{.Mailpit.SMTPHost}}
 ; https://php.net/smtp-port
-smtp_port={MAILPIT_SMTP_PORT}
+smtp_port={{.Mailpit.SMTPPort}}
 ; For Win32 only.
 ; https://php.net/sendmail-from
 ;sendmail_from = me@example.com
@@ -959,7 +927,7 @@ mail.mixed_lf_and_crlf=Off
 ;mail.log =
 ; Log mail to syslog (Event Log on Windows).
 ;mail.log = syslog
-;sendmail_path={PHP_SENDMAIL_PATH}
+;sendmail_path={{.PHP.SendmailPath}}
 
 [ODBC]
 ; https://php.net/odbc.default-db
@@ -1177,7 +1145,7 @@ session.save_handler=files
 ; does not overwrite the process's umask.
 ; https://php.net/session.save-path
 ;session.save_path = "/tmp"
-session.save_path={PHP_SESSION_SAVE_PATH}
+session.save_path={{.PHP.SessionSavePath}}
 ; Whether to use strict session mode.
 ; Strict session mode does not accept an uninitialized session ID, and
 ; regenerates the session ID if the browser sends an uninitialized session ID.
@@ -1634,7 +1602,7 @@ ldap.max_links=-1
 [curl]
 ; A default value for the CURLOPT_CAINFO option. This is required to be an
 ; absolute path.
-curl.cainfo={PHP_CURL_CAINFO}
+curl.cainfo={{.PHP.CurlCaInfo}}
 
 [openssl]
 ; The location of a Certificate Authority (CA) file on the local filesystem
@@ -1661,3 +1629,19 @@ curl.cainfo={PHP_CURL_CAINFO}
 ;ffi.enable=preload
 ; List of headers files to preload, wildcard patterns allowed.
 ;ffi.preload=
+{{- if .PHP.Xdebug.Enabled}}
+
+[xdebug]
+; Managed with "server xdebug on|off [--mode=debug,coverage]"
+xdebug.mode={{.PHP.Xdebug.Mode}}
+xdebug.client_host={{.PHP.Xdebug.ClientHost}}
+xdebug.client_port={{.PHP.Xdebug.ClientPort}}
+xdebug.start_with_request={{.PHP.Xdebug.StartWithRequest}}
+{{- end}}
+{{- range .PHP.Sections}}
+
+[{{.Name}}]
+{{- range $name, $value := .Values}}
+{{$name}}={{$value}}
+{{- end}}
+{{- end}}
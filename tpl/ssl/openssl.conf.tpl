@@ -21,3 +21,5 @@ extendedKeyUsage = serverAuth
 subjectAltName = @alt_names
 
 [alt_names]
+{{range .SSL.AltNames}}{{.}}
+{{end}}
\ No newline at end of file